@@ -0,0 +1,49 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// anonymousFallback wraps an authenticator, authenticating anonymously if no AWS credentials can be resolved.
+type anonymousFallback struct {
+	authenticator authn.Authenticator
+	credentials   aws.CredentialsProvider
+	logger        *slog.Logger
+	baseContext   context.Context
+}
+
+func (fallback *anonymousFallback) Authorization() (*authn.AuthConfig, error) {
+	return fallback.AuthorizationContext(fallback.baseContext)
+}
+
+// AuthorizationContext is like Authorization but ctx governs the AWS calls made.
+func (fallback *anonymousFallback) AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) {
+	authConfig, _, err := fallback.authorizationExpiry(ctx)
+	return authConfig, err
+}
+
+// authorizationExpiry reports the expiry of the wrapped authenticator, anonymous credentials never expire.
+func (fallback *anonymousFallback) authorizationExpiry(ctx context.Context) (*authn.AuthConfig, time.Time, error) {
+	err := errors.New("no credentials provider configured")
+	if fallback.credentials != nil {
+		_, err = fallback.credentials.Retrieve(ctx)
+	}
+	if err != nil {
+		fallback.logger.Debug("no AWS credentials available, using anonymous", "error", err)
+		authConfig, err := authn.Anonymous.Authorization()
+		return authConfig, time.Time{}, err
+	}
+	return authorization(ctx, fallback.authenticator)
+}
+
+func (fallback *anonymousFallback) invalidate() {
+	if authenticator, ok := fallback.authenticator.(invalidator); ok {
+		authenticator.invalidate()
+	}
+}
@@ -0,0 +1,116 @@
+package ecr
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// AssumeRole is a role the keychain assumes with sts:AssumeRole to fetch tokens for the registries of an account.
+type AssumeRole struct {
+	RoleARN     string
+	SessionName string
+	// ExternalID is required by roles that trust another account on the condition of sts:ExternalId, see WithDefaultExternalID.
+	ExternalID string
+	// SourceIdentity and Tags are recorded in CloudTrail for every call made with the role, such as the pipeline or user minting tokens.
+	SourceIdentity string
+	Tags           map[string]string
+}
+
+// provider returns the cached credentials of the role, assumed using the credentials of cfg.
+func (role AssumeRole) provider(cfg aws.Config, defaultExternalID string, optFns ...func(*sts.Options)) aws.CredentialsProvider {
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg, optFns...), role.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = role.SessionName
+		externalID := role.ExternalID
+		if externalID == "" {
+			externalID = defaultExternalID
+		}
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+		if role.SourceIdentity != "" {
+			o.SourceIdentity = aws.String(role.SourceIdentity)
+		}
+		for key, value := range role.Tags {
+			o.Tags = append(o.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		// Sort the tags so the request is deterministic.
+		sort.Slice(o.Tags, func(i, j int) bool {
+			return aws.ToString(o.Tags[i].Key) < aws.ToString(o.Tags[j].Key)
+		})
+	}))
+}
+
+// assumeRoleChain returns the credentials of the last of roles, each assumed using the credentials of the previous starting with those of cfg.
+func assumeRoleChain(cfg aws.Config, roles []AssumeRole, defaultExternalID string, optFns ...func(*sts.Options)) aws.CredentialsProvider {
+	for _, role := range roles {
+		cfg.Credentials = role.provider(cfg, defaultExternalID, optFns...)
+	}
+	return cfg.Credentials
+}
+
+// assumedRole returns the cached credentials of roles assumed for reg using the credentials of cfg.
+func (keychain *ecrKeychain) assumedRole(cfg aws.Config, reg *Registry, roles []AssumeRole) aws.CredentialsProvider {
+	cache := keychain.roleCache(reg)
+	if cache == nil {
+		// Without a loaded configuration to cache the role on, assume it anyway rather than falling back to the credentials of cfg.
+		return assumeRoleChain(cfg, roles, keychain.opts.defaultExternalID, keychain.stsOptions(reg))
+	}
+	key := reg.AccountID
+	if keychain.opts.stsEndpoint == STSEndpointRegional {
+		key += "/" + reg.Region
+	}
+	if provider, ok := cache.Load(key); ok {
+		return provider.(aws.CredentialsProvider)
+	}
+	provider, _ := cache.LoadOrStore(key, assumeRoleChain(cfg, roles, keychain.opts.defaultExternalID, keychain.stsOptions(reg)))
+	return provider.(aws.CredentialsProvider)
+}
+
+// roleCache returns the cache of the roles assumed with the AWS configuration used for reg, or nil if it has not been loaded yet.
+// The roles assumed with a configuration of WithConfigForPartition are cached separately from those of the default configuration.
+func (keychain *ecrKeychain) roleCache(reg *Registry) *sync.Map {
+	if roles, ok := keychain.partitionRoles[reg.Partition()]; ok {
+		return roles
+	}
+	if loaded := keychain.cfg.Load(); loaded != nil {
+		return &loaded.roles
+	}
+	return nil
+}
+
+// STSEndpoint selects the AWS STS endpoint roles are assumed with, see WithSTSEndpoint.
+type STSEndpoint int
+
+const (
+	// STSEndpointDefault uses the STS endpoint of the aws.Config region.
+	STSEndpointDefault STSEndpoint = iota
+	// STSEndpointRegional uses the STS endpoint of the region of the registry, which also works in isolated regions.
+	STSEndpointRegional
+	// STSEndpointGlobal uses the global sts.amazonaws.com endpoint of the aws partition.
+	STSEndpointGlobal
+)
+
+// stsOptions returns the options of the STS clients used to assume roles for reg.
+func (keychain *ecrKeychain) stsOptions(reg *Registry) func(*sts.Options) {
+	return func(o *sts.Options) {
+		switch keychain.opts.stsEndpoint {
+		case STSEndpointRegional:
+			o.Region = reg.Region
+		case STSEndpointGlobal:
+			o.Region = "us-east-1"
+			o.BaseEndpoint = aws.String("https://sts.amazonaws.com")
+		}
+		keychain.addAPIOptions(o)
+	}
+}
+
+// addAPIOptions adds the user agent and the middleware of WithAPIOptions to the APIOptions of an STS client.
+func (keychain *ecrKeychain) addAPIOptions(o *sts.Options) {
+	o.APIOptions = append(o.APIOptions, addUserAgent)
+	o.APIOptions = append(o.APIOptions, keychain.opts.apiOptions...)
+}
@@ -0,0 +1,190 @@
+package ecr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stsServer responds to sts:AssumeRole with credentials for AKIAROLE, recording the requests made.
+type stsServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newSTSServer(t *testing.T) *stsServer {
+	server := &stsServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		server.mu.Lock()
+		server.requests = append(server.requests, r)
+		server.mu.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>` +
+			`<AccessKeyId>AKIAROLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>` +
+			`<Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Requests returns the requests made so far.
+func (server *stsServer) Requests() []*http.Request {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]*http.Request(nil), server.requests...)
+}
+
+func TestKeychainAssumeRole(t *testing.T) {
+	t.Parallel()
+	server := newSTSServer(t)
+	keychain := NewKeychain(aws.Config{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIABASE", "secret", ""),
+	}, WithAssumeRole("210987654321", AssumeRole{
+		RoleARN:        "arn:aws:iam::210987654321:role/pull",
+		SessionName:    "ci",
+		SourceIdentity: "pipeline-1",
+		Tags:           map[string]string{"team": "platform", "build": "42"},
+	})).(*ecrKeychain)
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+
+	creds, err := keychain.credentials(cfg, Parse("210987654321.dkr.ecr.us-west-2.amazonaws.com")).Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAROLE", creds.AccessKeyID)
+	requests := server.Requests()
+	require.Len(t, requests, 1)
+	request := requests[0].PostForm
+	assert.Equal(t, "arn:aws:iam::210987654321:role/pull", request.Get("RoleArn"))
+	assert.Equal(t, "pipeline-1", request.Get("SourceIdentity"))
+	assert.Equal(t, "build", request.Get("Tags.member.1.Key"))
+	assert.Equal(t, "platform", request.Get("Tags.member.2.Value"))
+
+	creds, err = keychain.credentials(cfg, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")).Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIABASE", creds.AccessKeyID)
+
+	key, err := keychain.cacheKey(context.Background(), Parse("210987654321.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2/false/210987654321", key)
+}
+
+func TestKeychainAssumeRoleExternalID(t *testing.T) {
+	t.Parallel()
+	server := newSTSServer(t)
+	keychain := NewKeychain(aws.Config{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIABASE", "secret", ""),
+	},
+		WithDefaultExternalID("default-id"),
+		WithAssumeRole("123456789012", AssumeRole{RoleARN: "arn:aws:iam::123456789012:role/pull"}),
+		WithAssumeRole("210987654321", AssumeRole{RoleARN: "arn:aws:iam::210987654321:role/pull", ExternalID: "account-id"}),
+	).(*ecrKeychain)
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+	for _, host := range []string{"123456789012.dkr.ecr.us-west-2.amazonaws.com", "210987654321.dkr.ecr.us-west-2.amazonaws.com"} {
+		_, err := keychain.credentials(cfg, Parse(host)).Retrieve(context.Background())
+		require.NoError(t, err)
+	}
+	requests := server.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "default-id", requests[0].PostForm.Get("ExternalId"))
+	assert.Equal(t, "account-id", requests[1].PostForm.Get("ExternalId"))
+}
+
+func TestKeychainAssumeRoleChain(t *testing.T) {
+	t.Parallel()
+	server := newSTSServer(t)
+	keychain := NewKeychain(aws.Config{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIABASE", "secret", ""),
+	}, WithAssumeRole("210987654321",
+		AssumeRole{RoleARN: "arn:aws:iam::111111111111:role/bastion"},
+		AssumeRole{RoleARN: "arn:aws:iam::210987654321:role/pull"},
+	)).(*ecrKeychain)
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+	_, err = keychain.credentials(cfg, Parse("210987654321.dkr.ecr.us-west-2.amazonaws.com")).Retrieve(context.Background())
+	require.NoError(t, err)
+
+	requests := server.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/bastion", requests[0].PostForm.Get("RoleArn"))
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "Credential=AKIABASE/")
+	assert.Equal(t, "arn:aws:iam::210987654321:role/pull", requests[1].PostForm.Get("RoleArn"))
+	assert.Contains(t, requests[1].Header.Get("Authorization"), "Credential=AKIAROLE/")
+}
+
+func TestKeychainAssumeRoleConfigForPartition(t *testing.T) {
+	t.Parallel()
+	server := newSTSServer(t)
+	keychain := NewKeychain(aws.Config{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIABASE", "secret", ""),
+	},
+		WithConfigForPartition(map[string]aws.Config{"aws-cn": {
+			Region:       "cn-north-1",
+			BaseEndpoint: aws.String(server.URL),
+			Credentials:  credentials.NewStaticCredentialsProvider("AKIACN", "secret", ""),
+		}}),
+		WithAssumeRole("210987654321", AssumeRole{RoleARN: "arn:aws-cn:iam::210987654321:role/pull"}),
+	).(*ecrKeychain)
+	reg := Parse("210987654321.dkr.ecr.cn-north-1.amazonaws.com.cn")
+	cfg, err := keychain.configFor(context.Background(), reg)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		creds, err := keychain.credentials(cfg, reg).Retrieve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "AKIAROLE", creds.AccessKeyID)
+	}
+	requests := server.Requests()
+	require.Len(t, requests, 1)
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "Credential=AKIACN/")
+	assert.Nil(t, keychain.cfg.Load())
+
+	// The role assumed with the partition config is not reused for the default config.
+	cfg, err = keychain.config(context.Background())
+	require.NoError(t, err)
+	_, err = keychain.credentials(cfg, Parse("210987654321.dkr.ecr.us-west-2.amazonaws.com")).Retrieve(context.Background())
+	require.NoError(t, err)
+	requests = server.Requests()
+	require.Len(t, requests, 2)
+	assert.Contains(t, requests[1].Header.Get("Authorization"), "Credential=AKIABASE/")
+}
+
+func TestKeychainSTSEndpoint(t *testing.T) {
+	tests := map[STSEndpoint]struct {
+		region   string
+		endpoint string
+	}{
+		STSEndpointDefault:  {region: "us-west-2"},
+		STSEndpointRegional: {region: "eu-west-1"},
+		STSEndpointGlobal:   {region: "us-east-1", endpoint: "https://sts.amazonaws.com"},
+	}
+	for endpoint, tt := range tests {
+		endpoint, tt := endpoint, tt
+		t.Run(strconv.Itoa(int(endpoint)), func(t *testing.T) {
+			t.Parallel()
+			keychain := NewKeychain(aws.Config{}, WithSTSEndpoint(endpoint)).(*ecrKeychain)
+			o := sts.Options{Region: "us-west-2"}
+			keychain.stsOptions(Parse("123456789012.dkr.ecr.eu-west-1.amazonaws.com"))(&o)
+			assert.Equal(t, tt.region, o.Region)
+			assert.Equal(t, tt.endpoint, aws.ToString(o.BaseEndpoint))
+		})
+	}
+}
@@ -1,82 +1,289 @@
 package ecr
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	"github.com/google/go-containerregistry/pkg/authn"
 )
 
 // DefaultEarlyExpiry is used by NewAuthenticator when earlyExpiry is unspecified
 var DefaultEarlyExpiry = 15 * time.Minute
 
+// DefaultTokenLifetime is the lifetime assumed for tokens returned without an ExpiresAt, shorter than the 12 hours ECR tokens are valid for.
+var DefaultTokenLifetime = 11 * time.Hour
+
+// ErrRateLimited is returned by Authorization when a token must be fetched but WithRateLimit is exceeded in fail-fast mode.
+var ErrRateLimited = errors.New("GetAuthorizationToken rate limit exceeded")
+
+// ErrNotCached is returned by Authorization with WithCacheOnly when the shared Cache has no valid token.
+var ErrNotCached = errors.New("cache-only mode is enabled and the shared cache has no valid token")
+
+// ErrProxyEndpointMismatch is returned by Authorization with WithStrictProxyEndpoint when the token is for another registry.
+var ErrProxyEndpointMismatch = errors.New("GetAuthorizationToken returned a token for another registry")
+
+// ErrOffline is returned by Authorization in offline mode when no valid cached token is available.
+var ErrOffline = errors.New("offline mode is enabled and no valid cached token is available")
+
 type ecrClient interface {
 	GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
 }
 
+// funcGetAuthorizationToken adapts a function to the ecrClient interface.
+type funcGetAuthorizationToken func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+
+func (fn funcGetAuthorizationToken) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	return fn(ctx, params, optFns...)
+}
+
 // cachedAuthConfig is an authn.AuthConfig with an expiry time.
+// With WithEncryptedCache the AuthConfig is nil and the "username:password" is held encrypted in sealed instead.
 type cachedAuthConfig struct {
 	AuthConfig *authn.AuthConfig
 	ExpiresAt  time.Time
+	sealed     []byte
 }
 
 // ecrAuthenticator implements an authn.Authenticator that can authenticate to ECR.
 // It caches the authorization token until it expires reducing the round-trips to ECR.
 type ecrAuthenticator struct {
-	client      ecrClient
-	earlyExpiry time.Duration
-	cache       atomic.Pointer[cachedAuthConfig]
+	client ecrClient
+	opts   options
+	cache  atomic.Pointer[cachedAuthConfig]
 }
 
-func (authenticator *ecrAuthenticator) Authorization() (*authn.AuthConfig, error) {
+// expiresAt returns when a token ECR reported as expiring at expiry should be refreshed.
+func (authenticator *ecrAuthenticator) expiresAt(expiry time.Time) time.Time {
+	expiresAt := expiry.Add(-authenticator.opts.earlyExpiry)
+	if authenticator.opts.refreshFraction > 0 {
+		now := authenticator.opts.now()
+		expiresAt = now.Add(time.Duration(float64(expiry.Sub(now)) * authenticator.opts.refreshFraction))
+	}
+	if authenticator.opts.jitter > 0 {
+		expiresAt = expiresAt.Add(-rand.N(authenticator.opts.jitter))
+	}
+	if authenticator.opts.maxTokenAge > 0 {
+		if maxExpiresAt := authenticator.opts.now().Add(authenticator.opts.maxTokenAge); maxExpiresAt.Before(expiresAt) {
+			expiresAt = maxExpiresAt
+		}
+	}
+	return expiresAt
+}
+
+// token returns the cached token along with when it expires, fetching a new one from ECR if needed.
+func (authenticator *ecrAuthenticator) token(ctx context.Context) (*cachedAuthConfig, error) {
 	// Check if we have a cached token already and it hasn't expired.
-	if cached := authenticator.cache.Load(); cached != nil && time.Now().Before(cached.ExpiresAt) {
-		return cached.AuthConfig, nil
+	now := authenticator.opts.now()
+	if cached := authenticator.cache.Load(); cached != nil && now.Before(cached.ExpiresAt) {
+		authenticator.opts.logger.Debug("ecr token cache hit", "expires_at", cached.ExpiresAt, "expires_in", cached.ExpiresAt.Sub(now))
+		return cached, nil
+	}
+	if authenticator.opts.offline.Load() {
+		authenticator.opts.logger.Debug("ecr token cache miss in offline mode")
+		return nil, ErrOffline
 	}
+	if cached := authenticator.getShared(ctx); cached != nil {
+		return cached, nil
+	}
+	if authenticator.opts.cacheOnly {
+		authenticator.opts.logger.Debug("ecr shared token cache miss in cache-only mode")
+		return nil, ErrNotCached
+	}
+	authenticator.opts.logger.Debug("ecr token cache miss, calling GetAuthorizationToken")
 
+	if err := authenticator.opts.waitRateLimit(ctx); err != nil {
+		authenticator.opts.logger.Debug("ecr GetAuthorizationToken rate limited", "error", err)
+		return nil, err
+	}
 	// Fetch a new token from ECR.
-	out, err := authenticator.client.GetAuthorizationToken(context.TODO(), &ecr.GetAuthorizationTokenInput{})
+	input := &ecr.GetAuthorizationTokenInput{}
+	if reg := authenticator.opts.registry; authenticator.opts.strictProxyEndpoint && reg != nil && reg.DNSSuffix != ecrPublicDomain {
+		// Request the token of the registry itself, otherwise ECR returns the ProxyEndpoint of the caller's account.
+		input.RegistryIds = []string{reg.AccountID}
+	}
+	out, err := authenticator.client.GetAuthorizationToken(ctx, input)
 	if err != nil {
+		authenticator.opts.logger.Debug("ecr GetAuthorizationToken failed", "error", err)
+		if ssoErr := ssoLoginRequired(ctx, err, authenticator.opts.sharedConfig()); ssoErr != nil {
+			return nil, ssoErr
+		}
 		return nil, fmt.Errorf("(*ecr.Client).GetAuthorizationToken failed: %w", err)
 	} else if len(out.AuthorizationData) == 0 {
 		return nil, errors.New("(*ecr.Client).GetAuthorizationToken returned no authorization data")
 	}
 
+	data := authenticator.authorizationData(out.AuthorizationData)
+	if err := authenticator.checkProxyEndpoint(aws.ToString(data.ProxyEndpoint)); err != nil {
+		return nil, err
+	}
+	token := aws.ToString(data.AuthorizationToken)
+	expiry := aws.ToTime(data.ExpiresAt)
+	if data.ExpiresAt == nil {
+		// Without an expiry the token would be treated as already expired and fetched again on every call.
+		expiry = authenticator.opts.now().Add(authenticator.opts.defaultTokenLifetime)
+		authenticator.opts.logger.Warn("ecr GetAuthorizationToken returned no ExpiresAt, assuming the default token lifetime", "lifetime", authenticator.opts.defaultTokenLifetime)
+	}
+	refreshAt := authenticator.expiresAt(expiry)
+	cached, err := authenticator.store(token, refreshAt)
+	if err != nil {
+		return nil, fmt.Errorf("(*ecr.Client).GetAuthorizationToken returned an %w", err)
+	}
+	authenticator.putShared(ctx, token, expiry, refreshAt)
+	return cached, nil
+}
+
+// authorizationData returns the entry of data whose ProxyEndpoint is the registry of the authenticator.
+// It falls back to the first entry in the same region and then to the first entry, as the token of any entry is valid for every registry the caller can access.
+func (authenticator *ecrAuthenticator) authorizationData(data []types.AuthorizationData) types.AuthorizationData {
+	reg := authenticator.opts.registry
+	if len(data) == 1 || reg == nil {
+		return data[0]
+	}
+	fallback := data[0]
+	sameRegion := false
+	for _, entry := range data {
+		proxy := Parse(aws.ToString(entry.ProxyEndpoint))
+		if proxy == nil || proxy.Region != reg.Region {
+			continue
+		}
+		if proxy.AccountID == reg.AccountID {
+			return entry
+		}
+		if !sameRegion {
+			fallback, sameRegion = entry, true
+		}
+	}
+	return fallback
+}
+
+// checkProxyEndpoint returns ErrProxyEndpointMismatch with WithStrictProxyEndpoint if endpoint is not in the account and region of the registry.
+func (authenticator *ecrAuthenticator) checkProxyEndpoint(endpoint string) error {
+	reg := authenticator.opts.registry
+	if !authenticator.opts.strictProxyEndpoint || reg == nil || reg.DNSSuffix == ecrPublicDomain {
+		return nil
+	}
+	proxy := Parse(endpoint)
+	if proxy == nil || proxy.AccountID != reg.AccountID || proxy.Region != reg.Region {
+		authenticator.opts.logger.Debug("ecr ProxyEndpoint mismatch", "proxy_endpoint", endpoint)
+		return fmt.Errorf("%w: ProxyEndpoint %q does not match %s", ErrProxyEndpointMismatch, endpoint, reg)
+	}
+	return nil
+}
+
+// store decodes token, caching the username and password until refreshAt.
+func (authenticator *ecrAuthenticator) store(token string, refreshAt time.Time) (*cachedAuthConfig, error) {
 	// Decode the token and extract the username and password just once
-	token := aws.ToString(out.AuthorizationData[0].AuthorizationToken)
-	expiry := aws.ToTime(out.AuthorizationData[0].ExpiresAt)
 	tokenBytes, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
-		return nil, fmt.Errorf("(*ecr.Client).GetAuthorizationToken returned an invalid token: %w", err)
+		return nil, fmt.Errorf("invalid token: %w", err)
 	}
-	username, password, ok := strings.Cut(string(tokenBytes), ":")
-	if !ok {
-		return nil, errors.New("(*ecr.Client).GetAuthorizationToken returned an invalid token: missing ':'")
+	defer clear(tokenBytes)
+	if !bytes.Contains(tokenBytes, []byte(":")) {
+		return nil, errors.New("invalid token: missing ':'")
 	}
-	authConfig := &authn.AuthConfig{Username: username, Password: password}
 
 	// Cache the result and return it.
-	authenticator.cache.Store(&cachedAuthConfig{
-		AuthConfig: authConfig,
-		ExpiresAt:  expiry.Add(-authenticator.earlyExpiry),
-	})
-	return authConfig, nil
+	cached := &cachedAuthConfig{ExpiresAt: refreshAt}
+	if authenticator.opts.sealer != nil {
+		cached.sealed = authenticator.opts.sealer.seal(tokenBytes)
+	} else {
+		cached.AuthConfig = decodeAuthConfig(tokenBytes)
+	}
+	authenticator.cache.Store(cached)
+	authenticator.opts.logger.Debug("ecr token refreshed", "expires_at", cached.ExpiresAt, "expires_in", cached.ExpiresAt.Sub(authenticator.opts.now()))
+	if authenticator.opts.onRefresh != nil {
+		authenticator.opts.onRefresh(cached.ExpiresAt)
+	}
+	return cached, nil
+}
+
+// decodeAuthConfig splits a decoded "username:password" token into an authn.AuthConfig.
+func decodeAuthConfig(tokenBytes []byte) *authn.AuthConfig {
+	username, password, _ := strings.Cut(string(tokenBytes), ":")
+	return &authn.AuthConfig{Username: username, Password: password}
+}
+
+// authConfig returns the credentials of cached, decrypting them if they are sealed.
+func (authenticator *ecrAuthenticator) authConfig(cached *cachedAuthConfig) (*authn.AuthConfig, error) {
+	if cached.sealed == nil {
+		return cached.AuthConfig, nil
+	}
+	tokenBytes, err := authenticator.opts.sealer.open(cached.sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cached token: %w", err)
+	}
+	defer clear(tokenBytes)
+	return decodeAuthConfig(tokenBytes), nil
+}
+
+func (authenticator *ecrAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	return authenticator.AuthorizationContext(authenticator.opts.baseContext)
+}
+
+// AuthorizationContext is like Authorization but ctx governs the GetAuthorizationToken call if a new token must be fetched.
+func (authenticator *ecrAuthenticator) AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) {
+	cached, err := authenticator.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return authenticator.authConfig(cached)
+}
+
+func (authenticator *ecrAuthenticator) authorizationExpiry(ctx context.Context) (*authn.AuthConfig, time.Time, error) {
+	cached, err := authenticator.token(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	authConfig, err := authenticator.authConfig(cached)
+	return authConfig, cached.ExpiresAt, err
+}
+
+// invalidate discards the cached token so the next Authorization fetches a new one.
+func (authenticator *ecrAuthenticator) invalidate() {
+	authenticator.cache.Store(nil)
+}
+
+// waitRateLimit blocks until the rate limit of WithRateLimit allows a GetAuthorizationToken call.
+func (o *options) waitRateLimit(ctx context.Context) error {
+	if o.limiter == nil {
+		return nil
+	}
+	if o.rateLimitFailFast {
+		if !o.limiter.Allow() {
+			return ErrRateLimited
+		}
+		return nil
+	}
+	return o.limiter.Wait(ctx)
+}
+
+// newAuthenticator returns a new ecrAuthenticator backed by client.
+func newAuthenticator(client ecrClient, opts options) *ecrAuthenticator {
+	return &ecrAuthenticator{client: client, opts: opts}
 }
 
 // NewAuthenticatorWithEarlyExpiry returns a new Authenticator instance with a custom earlyExpiry value.
 func NewAuthenticatorWithEarlyExpiry(client *ecr.Client, earlyExpiry time.Duration) authn.Authenticator {
-	return &ecrAuthenticator{client: client, earlyExpiry: earlyExpiry}
+	return NewAuthenticator(client, WithEarlyExpiry(earlyExpiry))
 }
 
 // NewAuthenticator returns a new Authenticator instance from the given ECR client.
-func NewAuthenticator(client *ecr.Client) authn.Authenticator {
-	return NewAuthenticatorWithEarlyExpiry(client, DefaultEarlyExpiry)
+func NewAuthenticator(client *ecr.Client, opts ...Option) authn.Authenticator {
+	return newAuthenticator(client, newOptions(opts))
 }
 
+// NewAuthenticatorFromTokenFunc returns a new Authenticator instance that obtains tokens from fn instead of an ECR client.
+// fn has the signature of (*ecr.Client).GetAuthorizationToken, allowing mocked ECR, broker services or pre-fetched tokens to reuse the caching and decoding logic.
+func NewAuthenticatorFromTokenFunc(fn func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error), earlyExpiry time.Duration, opts ...Option) authn.Authenticator {
+	return newAuthenticator(funcGetAuthorizationToken(fn), newOptions(append([]Option{WithEarlyExpiry(earlyExpiry)}, opts...)))
+}
@@ -1,7 +1,334 @@
 package ecr
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// fakeClient implements ecrClient returning a fixed token and counting the calls made.
+type fakeClient struct {
+	calls     atomic.Int32
+	token     string
+	expiresAt time.Time
+	err       error
+}
+
+func (c *fakeClient) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	c.calls.Add(1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []types.AuthorizationData{{
+			AuthorizationToken: aws.String(c.token),
+			ExpiresAt:          aws.Time(c.expiresAt),
+		}},
+	}, nil
+}
+
+func newFakeClient(username, password string, lifetime time.Duration) *fakeClient {
+	return &fakeClient{
+		token:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+		expiresAt: time.Now().Add(lifetime),
+	}
+}
 
 func TestAuthenticator(t *testing.T) {
 	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := newAuthenticator(client, newOptions(nil))
+	for i := 0; i < 3; i++ {
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "AWS", authConfig.Username)
+		assert.Equal(t, "password", authConfig.Password)
+	}
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestAuthenticatorEarlyExpiry(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 10*time.Minute)
+	authenticator := newAuthenticator(client, newOptions(nil))
+	for i := 0; i < 2; i++ {
+		_, err := authenticator.Authorization()
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 2, client.calls.Load())
+}
+
+func TestAuthenticatorLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := newAuthenticator(client, newOptions([]Option{WithLogger(logger)}))
+	for i := 0; i < 2; i++ {
+		_, err := authenticator.Authorization()
+		require.NoError(t, err)
+	}
+	assert.Contains(t, buf.String(), "ecr token cache miss")
+	assert.Contains(t, buf.String(), "ecr token cache hit")
+	assert.Contains(t, buf.String(), "ecr token refreshed")
+	assert.Contains(t, buf.String(), `"expires_in":`)
+	assert.NotContains(t, buf.String(), "password")
+}
+
+func TestAuthenticatorJitter(t *testing.T) {
+	t.Parallel()
+	authenticator := newAuthenticator(nil, newOptions([]Option{WithEarlyExpiry(time.Minute), WithJitter(time.Hour)}))
+	expiry := time.Now().Add(12 * time.Hour)
+	for i := 0; i < 100; i++ {
+		expiresAt := authenticator.expiresAt(expiry)
+		assert.True(t, expiresAt.After(expiry.Add(-time.Minute-time.Hour)))
+		assert.False(t, expiresAt.After(expiry.Add(-time.Minute)))
+	}
+}
+
+func TestAuthenticatorMaxTokenAge(t *testing.T) {
+	t.Parallel()
+	authenticator := newAuthenticator(nil, newOptions([]Option{WithMaxTokenAge(time.Hour)}))
+	expiresAt := authenticator.expiresAt(time.Now().Add(12 * time.Hour))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	expiresAt = authenticator.expiresAt(time.Now().Add(30 * time.Minute))
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), expiresAt, time.Minute)
+}
+
+func TestNewAuthenticatorFromTokenFunc(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := NewAuthenticatorFromTokenFunc(client.GetAuthorizationToken, time.Minute)
+	for i := 0; i < 2; i++ {
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "AWS", authConfig.Username)
+	}
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestAuthenticatorClock(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := newAuthenticator(client, newOptions([]Option{WithClock(func() time.Time { return now })}))
+	_, err := authenticator.Authorization()
+	require.NoError(t, err)
+
+	now = client.expiresAt.Add(-DefaultEarlyExpiry - time.Second)
+	_, err = authenticator.Authorization()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, client.calls.Load())
+
+	now = client.expiresAt.Add(-DefaultEarlyExpiry)
+	_, err = authenticator.Authorization()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, client.calls.Load())
+}
+
+func TestAuthenticatorRateLimit(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 10*time.Minute)
+	authenticator := newAuthenticator(client, newOptions([]Option{WithRateLimit(rate.Every(time.Hour), 1, true)}))
+	_, err := authenticator.Authorization()
+	require.NoError(t, err)
+	// The token expires within the early expiry so a new one must be fetched.
+	_, err = authenticator.Authorization()
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestAuthenticatorEncryptedCache(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := newAuthenticator(client, newOptions([]Option{WithEncryptedCache()}))
+	for i := 0; i < 2; i++ {
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, &authn.AuthConfig{Username: "AWS", Password: "password"}, authConfig)
+	}
+	assert.Equal(t, int32(1), client.calls.Load())
+	cached := authenticator.cache.Load()
+	assert.Nil(t, cached.AuthConfig)
+	assert.NotContains(t, string(cached.sealed), "password")
+}
+
+func TestAuthenticatorContext(t *testing.T) {
+	t.Parallel()
+	fn := func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewAuthenticatorFromTokenFunc(fn, 0).(*ecrAuthenticator).AuthorizationContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = NewAuthenticatorFromTokenFunc(fn, 0, WithBaseContext(ctx)).Authorization()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAuthenticatorRefreshFraction(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	client := &fakeClient{token: base64.StdEncoding.EncodeToString([]byte("AWS:password")), expiresAt: now.Add(10 * time.Minute)}
+	authenticator := newAuthenticator(client, newOptions([]Option{WithRefreshFraction(0.8), WithClock(func() time.Time { return now })}))
+	_, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(8*time.Minute), authenticator.cache.Load().ExpiresAt)
+}
+
+func TestAuthenticatorStrictProxyEndpoint(t *testing.T) {
+	t.Parallel()
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	token := base64.StdEncoding.EncodeToString([]byte("AWS:password"))
+	tests := map[string]struct {
+		registry      *Registry
+		strict        bool
+		proxyEndpoint string
+		err           bool
+	}{
+		"match":         {registry: reg, strict: true, proxyEndpoint: "https://123456789012.dkr.ecr.us-west-2.amazonaws.com"},
+		"other account": {registry: reg, strict: true, proxyEndpoint: "https://210987654321.dkr.ecr.us-west-2.amazonaws.com", err: true},
+		"other region":  {registry: reg, strict: true, proxyEndpoint: "https://123456789012.dkr.ecr.us-east-1.amazonaws.com", err: true},
+		"missing":       {registry: reg, strict: true, err: true},
+		"not strict":    {registry: reg, proxyEndpoint: "https://210987654321.dkr.ecr.us-west-2.amazonaws.com"},
+		"no registry":   {strict: true, proxyEndpoint: "https://210987654321.dkr.ecr.us-west-2.amazonaws.com"},
+		"public":        {registry: Parse("public.ecr.aws"), strict: true},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var registryIDs []string
+			client := funcGetAuthorizationToken(func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+				registryIDs = params.RegistryIds
+				return &ecr.GetAuthorizationTokenOutput{
+					AuthorizationData: []types.AuthorizationData{{
+						AuthorizationToken: aws.String(token),
+						ExpiresAt:          aws.Time(time.Now().Add(12 * time.Hour)),
+						ProxyEndpoint:      aws.String(tt.proxyEndpoint),
+					}},
+				}, nil
+			})
+			var opts []Option
+			if tt.strict {
+				opts = append(opts, WithStrictProxyEndpoint())
+			}
+			options := newOptions(opts)
+			options.registry = tt.registry
+			authConfig, err := newAuthenticator(client, options).Authorization()
+			if tt.err {
+				assert.ErrorIs(t, err, ErrProxyEndpointMismatch)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "password", authConfig.Password)
+			}
+			if tt.strict && tt.registry == reg {
+				assert.Equal(t, []string{"123456789012"}, registryIDs)
+			} else {
+				assert.Empty(t, registryIDs)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorMultipleAuthorizationData(t *testing.T) {
+	t.Parallel()
+	entry := func(password, proxyEndpoint string) types.AuthorizationData {
+		return types.AuthorizationData{
+			AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:" + password))),
+			ExpiresAt:          aws.Time(time.Now().Add(12 * time.Hour)),
+			ProxyEndpoint:      aws.String(proxyEndpoint),
+		}
+	}
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	tests := map[string]struct {
+		registry *Registry
+		data     []types.AuthorizationData
+		password string
+	}{
+		"single": {
+			registry: reg,
+			data:     []types.AuthorizationData{entry("first", "https://210987654321.dkr.ecr.us-east-1.amazonaws.com")},
+			password: "first",
+		},
+		"match": {
+			registry: reg,
+			data: []types.AuthorizationData{
+				entry("first", "https://210987654321.dkr.ecr.us-west-2.amazonaws.com"),
+				entry("second", "https://123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+			},
+			password: "second",
+		},
+		"same region": {
+			registry: reg,
+			data: []types.AuthorizationData{
+				entry("first", "https://123456789012.dkr.ecr.us-east-1.amazonaws.com"),
+				entry("second", "https://210987654321.dkr.ecr.us-west-2.amazonaws.com"),
+			},
+			password: "second",
+		},
+		"no match": {
+			registry: reg,
+			data: []types.AuthorizationData{
+				entry("first", "https://210987654321.dkr.ecr.us-east-1.amazonaws.com"),
+				entry("second", "invalid"),
+			},
+			password: "first",
+		},
+		"no registry": {
+			data: []types.AuthorizationData{
+				entry("first", "https://210987654321.dkr.ecr.us-west-2.amazonaws.com"),
+				entry("second", "https://123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+			},
+			password: "first",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client := funcGetAuthorizationToken(func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+				return &ecr.GetAuthorizationTokenOutput{AuthorizationData: tt.data}, nil
+			})
+			options := newOptions(nil)
+			options.registry = tt.registry
+			authConfig, err := newAuthenticator(client, options).Authorization()
+			require.NoError(t, err)
+			assert.Equal(t, tt.password, authConfig.Password)
+		})
+	}
+}
+
+func TestAuthenticatorMissingExpiresAt(t *testing.T) {
+	t.Parallel()
+	client := &fakeClient{token: base64.StdEncoding.EncodeToString([]byte("AWS:password"))}
+	missing := funcGetAuthorizationToken(func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+		out, err := client.GetAuthorizationToken(ctx, params, optFns...)
+		out.AuthorizationData[0].ExpiresAt = nil
+		return out, err
+	})
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	authenticator := newAuthenticator(missing, newOptions([]Option{WithLogger(logger), WithDefaultTokenLifetime(time.Hour)}))
+	for i := 0; i < 3; i++ {
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "password", authConfig.Password)
+	}
+	assert.EqualValues(t, 1, client.calls.Load())
+	assert.WithinDuration(t, time.Now().Add(45*time.Minute), authenticator.cache.Load().ExpiresAt, time.Minute)
+	assert.Contains(t, buf.String(), "no ExpiresAt")
 }
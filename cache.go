@@ -0,0 +1,66 @@
+package ecr
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is a store of ECR authorization tokens shared between processes, such as the ephemeral runners of a CI fleet.
+// Tokens grant the permissions of the principal that fetched them, so a Cache must only be shared by processes using the same principal.
+type Cache interface {
+	// Get returns the token cached under key, nil if there is none.
+	Get(ctx context.Context, key string) (*CachedToken, error)
+	// Put caches token under key, it may be discarded once it expires.
+	Put(ctx context.Context, key string, token *CachedToken) error
+}
+
+// CachedToken is an ECR authorization token stored in a Cache.
+type CachedToken struct {
+	// Token is the base64 encoded "username:password" returned by GetAuthorizationToken.
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// RefreshAt is when the process that fetched the token refreshes it, so every process sharing it agrees on when it is stale.
+	// It is computed once with the early expiry, WithRefreshFraction and WithJitter, tokens without it fall back to the options of the reader.
+	RefreshAt time.Time `json:"refresh_at,omitempty"`
+}
+
+// getShared returns the token of the Cache of WithCache if it has one that is not about to expire.
+func (authenticator *ecrAuthenticator) getShared(ctx context.Context) *cachedAuthConfig {
+	if authenticator.opts.sharedCache == nil {
+		return nil
+	}
+	logger := authenticator.opts.logger.With("shared_key", authenticator.opts.sharedCacheKey)
+	token, err := authenticator.opts.sharedCache.Get(ctx, authenticator.opts.sharedCacheKey)
+	if err != nil {
+		logger.Debug("ecr shared token cache get failed", "error", err)
+		return nil
+	} else if token == nil {
+		logger.Debug("ecr shared token cache miss")
+		return nil
+	}
+	refreshAt := token.RefreshAt
+	if refreshAt.IsZero() {
+		refreshAt = authenticator.expiresAt(token.ExpiresAt)
+	}
+	if !authenticator.opts.now().Before(refreshAt) {
+		logger.Debug("ecr shared token cache miss", "refresh_at", refreshAt)
+		return nil
+	}
+	cached, err := authenticator.store(token.Token, refreshAt)
+	if err != nil {
+		logger.Debug("ecr shared token cache returned an invalid token", "error", err)
+		return nil
+	}
+	logger.Debug("ecr shared token cache hit", "expires_at", cached.ExpiresAt)
+	return cached
+}
+
+// putShared stores a token fetched from ECR in the Cache of WithCache, failures are only logged.
+func (authenticator *ecrAuthenticator) putShared(ctx context.Context, token string, expiry, refreshAt time.Time) {
+	if authenticator.opts.sharedCache == nil {
+		return
+	}
+	if err := authenticator.opts.sharedCache.Put(ctx, authenticator.opts.sharedCacheKey, &CachedToken{Token: token, ExpiresAt: expiry, RefreshAt: refreshAt}); err != nil {
+		authenticator.opts.logger.Debug("ecr shared token cache put failed", "shared_key", authenticator.opts.sharedCacheKey, "error", err)
+	}
+}
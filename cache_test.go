@@ -0,0 +1,90 @@
+package ecr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache implements Cache with an in-memory map.
+type mapCache struct {
+	mu     sync.Mutex
+	tokens map[string]*CachedToken
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) (*CachedToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[key], nil
+}
+
+func (c *mapCache) Put(ctx context.Context, key string, token *CachedToken) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = token
+	return nil
+}
+
+func TestKeychainCache(t *testing.T) {
+	t.Parallel()
+	cache := &mapCache{tokens: make(map[string]*CachedToken)}
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	for i := 0; i < 2; i++ {
+		keychain := NewKeychain(aws.Config{}, WithCache(cache, "ci")).(*ecrKeychain)
+		authenticator := keychain.newAuthenticator(aws.Config{}, reg, "us-west-2/false", keychain.opts.logger).(*ecrAuthenticator)
+		authenticator.client = client
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "password", authConfig.Password)
+	}
+	assert.EqualValues(t, 1, client.calls.Load())
+	require.Contains(t, cache.tokens, "ci/us-west-2/false")
+	assert.Equal(t, client.expiresAt, cache.tokens["ci/us-west-2/false"].ExpiresAt)
+}
+
+func TestKeychainCacheOnly(t *testing.T) {
+	t.Parallel()
+	cache := &mapCache{tokens: make(map[string]*CachedToken)}
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	consumer := NewKeychain(aws.Config{}, WithCache(cache, "ci"), WithCacheOnly()).(*ecrKeychain)
+	authenticator := consumer.newAuthenticator(aws.Config{}, reg, "us-west-2/false", consumer.opts.logger).(*ecrAuthenticator)
+	authenticator.client = client
+	_, err := authenticator.Authorization()
+	assert.ErrorIs(t, err, ErrNotCached)
+
+	cache.tokens["ci/us-west-2/false"] = &CachedToken{Token: client.token, ExpiresAt: client.expiresAt}
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+	assert.EqualValues(t, 0, client.calls.Load())
+}
+
+func TestKeychainCacheRefreshAt(t *testing.T) {
+	t.Parallel()
+	cache := &mapCache{tokens: make(map[string]*CachedToken)}
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	writer := NewKeychain(aws.Config{}, WithCache(cache, "ci"), WithJitter(time.Hour)).(*ecrKeychain)
+	authenticator := writer.newAuthenticator(aws.Config{}, reg, "us-west-2/false", writer.opts.logger).(*ecrAuthenticator)
+	authenticator.client = client
+	_, err := authenticator.Authorization()
+	require.NoError(t, err)
+	require.Contains(t, cache.tokens, "ci/us-west-2/false")
+	assert.Equal(t, authenticator.cache.Load().ExpiresAt, cache.tokens["ci/us-west-2/false"].RefreshAt)
+
+	// A reader honors the stored deadline even when its own options would still consider the token fresh.
+	cache.tokens["ci/us-west-2/false"].RefreshAt = time.Now().Add(-time.Minute)
+	reader := NewKeychain(aws.Config{}, WithCache(cache, "ci")).(*ecrKeychain)
+	authenticator = reader.newAuthenticator(aws.Config{}, reg, "us-west-2/false", reader.opts.logger).(*ecrAuthenticator)
+	authenticator.client = client
+	_, err = authenticator.Authorization()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, client.calls.Load())
+}
@@ -0,0 +1,124 @@
+package ecr
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// KeychainMiddleware wraps an authn.Keychain to layer cross-cutting behavior on top of it.
+type KeychainMiddleware func(authn.Keychain) authn.Keychain
+
+// Chain wraps keychain with middlewares, the first middleware being the outermost.
+func Chain(keychain authn.Keychain, middlewares ...KeychainMiddleware) authn.Keychain {
+	for idx := len(middlewares) - 1; idx >= 0; idx-- {
+		keychain = middlewares[idx](keychain)
+	}
+	return keychain
+}
+
+// AuthenticatorMiddleware wraps an authn.Authenticator, such as to rate limit or trace its Authorization calls, see WithAuthenticatorMiddleware.
+type AuthenticatorMiddleware func(authn.Authenticator) authn.Authenticator
+
+// middlewareAuthenticator is the authenticator returned by the middlewares of WithAuthenticatorMiddleware.
+// It keeps the authenticator they wrap reachable so the expiry, invalidation and GC of its token still work.
+type middlewareAuthenticator struct {
+	authn.Authenticator
+	inner authn.Authenticator
+}
+
+// AuthorizationContext passes ctx on to the outermost middleware if it implements AuthorizationContext.
+func (authenticator *middlewareAuthenticator) AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) {
+	return authorizationContext(ctx, authenticator.Authenticator)
+}
+
+func (authenticator *middlewareAuthenticator) authorizationExpiry(ctx context.Context) (*authn.AuthConfig, time.Time, error) {
+	authConfig, err := authorizationContext(ctx, authenticator.Authenticator)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// Middlewares normally delegate to inner, so its token is cached by now and not fetched again.
+	_, expiresAt, err := authorization(ctx, authenticator.inner)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return authConfig, expiresAt, nil
+}
+
+func (authenticator *middlewareAuthenticator) invalidate() {
+	if inner, ok := authenticator.inner.(invalidator); ok {
+		inner.invalidate()
+	}
+}
+
+func (authenticator *middlewareAuthenticator) dropExpired(now time.Time) {
+	if inner, ok := authenticator.inner.(expirer); ok {
+		inner.dropExpired(now)
+	}
+}
+
+// resolveFunc adapts a function to authn.Keychain, passing the context of ResolveContext through.
+type resolveFunc func(ctx context.Context, resource authn.Resource) (authn.Authenticator, error)
+
+func (fn resolveFunc) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	return fn(context.Background(), resource)
+}
+
+func (fn resolveFunc) ResolveContext(ctx context.Context, resource authn.Resource) (authn.Authenticator, error) {
+	return fn(ctx, resource)
+}
+
+// LoggingMiddleware emits a debug level record to logger for every Resolve with its registry, latency and error.
+func LoggingMiddleware(logger *slog.Logger) KeychainMiddleware {
+	return func(next authn.Keychain) authn.Keychain {
+		return resolveFunc(func(ctx context.Context, resource authn.Resource) (authn.Authenticator, error) {
+			start := time.Now()
+			authenticator, err := resolveContext(ctx, next, resource)
+			logger.DebugContext(ctx, "resolved registry", "registry", resource.RegistryStr(), "latency", time.Since(start), "error", err)
+			return authenticator, err
+		})
+	}
+}
+
+// ResolveMetrics describes a Resolve observed by MetricsMiddleware.
+type ResolveMetrics struct {
+	Registry  string
+	Latency   time.Duration
+	Anonymous bool
+	Err       error
+}
+
+// MetricsMiddleware calls record for every Resolve, such as to export resolve latencies and errors per registry.
+func MetricsMiddleware(record func(ResolveMetrics)) KeychainMiddleware {
+	return func(next authn.Keychain) authn.Keychain {
+		return resolveFunc(func(ctx context.Context, resource authn.Resource) (authn.Authenticator, error) {
+			start := time.Now()
+			authenticator, err := resolveContext(ctx, next, resource)
+			record(ResolveMetrics{
+				Registry:  resource.RegistryStr(),
+				Latency:   time.Since(start),
+				Anonymous: err == nil && authenticator == authn.Anonymous,
+				Err:       err,
+			})
+			return authenticator, err
+		})
+	}
+}
+
+// AllowlistMiddleware resolves registries not matching any of globs (using path.Match) to authn.Anonymous,
+// so credentials are never sent to registries outside of the allowlist.
+func AllowlistMiddleware(globs ...string) KeychainMiddleware {
+	return func(next authn.Keychain) authn.Keychain {
+		return resolveFunc(func(ctx context.Context, resource authn.Resource) (authn.Authenticator, error) {
+			for _, glob := range globs {
+				if ok, _ := path.Match(glob, resource.RegistryStr()); ok {
+					return resolveContext(ctx, next, resource)
+				}
+			}
+			return authn.Anonymous, nil
+		})
+	}
+}
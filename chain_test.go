@@ -0,0 +1,76 @@
+package ecr
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	var metrics []ResolveMetrics
+	keychain := Chain(newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour)),
+		LoggingMiddleware(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		MetricsMiddleware(func(m ResolveMetrics) { metrics = append(metrics, m) }),
+		AllowlistMiddleware("123456789012.dkr.ecr.*.amazonaws.com"),
+	)
+
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.IsType(t, &ecrAuthenticator{}, authenticator)
+
+	authenticator, err = keychain.Resolve(registryResource("210987654321.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "123456789012.dkr.ecr.us-west-2.amazonaws.com", metrics[0].Registry)
+	assert.False(t, metrics[0].Anonymous)
+	assert.True(t, metrics[1].Anonymous)
+	assert.Contains(t, buf.String(), "registry=210987654321.dkr.ecr.us-west-2.amazonaws.com")
+}
+
+// countingAuthenticator counts the Authorization calls made to an authn.Authenticator.
+type countingAuthenticator struct {
+	authn.Authenticator
+	calls *int
+}
+
+func (authenticator countingAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	*authenticator.calls++
+	return authenticator.Authenticator.Authorization()
+}
+
+func TestKeychainAuthenticatorMiddleware(t *testing.T) {
+	t.Parallel()
+	var order []string
+	var calls int
+	keychain := NewKeychain(aws.Config{Region: "us-west-2"}, WithAuthenticatorMiddleware(
+		func(next authn.Authenticator) authn.Authenticator {
+			order = append(order, "outer")
+			return countingAuthenticator{Authenticator: next, calls: &calls}
+		},
+		func(next authn.Authenticator) authn.Authenticator {
+			order = append(order, "inner")
+			return next
+		},
+	)).(*ecrKeychain)
+	authenticator := keychain.newAuthenticator(aws.Config{}, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"), "us-west-2/false", keychain.opts.logger)
+	assert.Equal(t, []string{"inner", "outer"}, order)
+	middleware, ok := authenticator.(*middlewareAuthenticator)
+	require.True(t, ok)
+	wrapped, ok := middleware.Authenticator.(countingAuthenticator)
+	require.True(t, ok)
+	assert.Same(t, wrapped.Authenticator, middleware.inner)
+	wrapped.Authenticator.(*ecrAuthenticator).client = newFakeClient("AWS", "password", 12*time.Hour)
+	_, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,87 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// maxClockSkew is how far the signing time may drift from the server's clock before SigV4 requests are rejected.
+const maxClockSkew = 5 * time.Minute
+
+// isClockSkewError reports whether err is AWS rejecting a request because its signing time is too far from the server's clock.
+func isClockSkewError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "RequestTimeTooSkewed", "RequestExpired", "RequestInTheFuture":
+		return true
+	case "InvalidSignatureException", "SignatureDoesNotMatch":
+		msg := apiErr.ErrorMessage()
+		return strings.Contains(msg, "Signature expired") || strings.Contains(msg, "Signature not yet current")
+	default:
+		return false
+	}
+}
+
+// serverTime returns the time reported in the Date header of the response that caused err.
+func serverTime(err error) (time.Time, bool) {
+	var respErr *smithyhttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.Response == nil {
+		return time.Time{}, false
+	}
+	date, err := http.ParseTime(respErr.Response.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// skewSigner is an ecr.HTTPSignerV4 that shifts the signing time by the observed clock skew.
+type skewSigner struct {
+	signer ecr.HTTPSignerV4
+	offset atomic.Int64
+}
+
+func (s *skewSigner) SignHTTP(ctx context.Context, credentials aws.Credentials, r *http.Request, payloadHash string, service string, region string, signingTime time.Time, optFns ...func(*v4.SignerOptions)) error {
+	return s.signer.SignHTTP(ctx, credentials, r, payloadHash, service, region, signingTime.Add(time.Duration(s.offset.Load())), optFns...)
+}
+
+// skewClient wraps an ECR client whose requests are signed by signer.
+// When a request is rejected due to clock skew it is retried once with the signing time corrected by the offset from the server's Date header.
+type skewClient struct {
+	client ecrClient
+	signer *skewSigner
+}
+
+func (c *skewClient) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	out, err := c.client.GetAuthorizationToken(ctx, params, optFns...)
+	if !isClockSkewError(err) {
+		return out, err
+	}
+	if date, ok := serverTime(err); ok {
+		// The offset is measured against the clock the SDK signs with rather than the previous offset, so a corrected host clock resets it.
+		skew := date.Sub(time.Now())
+		if skew <= maxClockSkew && skew >= -maxClockSkew {
+			skew = 0
+		}
+		if previous := c.signer.offset.Swap(int64(skew)); previous != int64(skew) {
+			if out, err = c.client.GetAuthorizationToken(ctx, params, optFns...); !isClockSkewError(err) {
+				return out, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("request was rejected due to clock skew, check your system clock: %w", err)
+}
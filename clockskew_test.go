@@ -0,0 +1,99 @@
+package ecr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSkewedServer returns a server whose clock is ahead by skew, rejecting requests signed more than maxClockSkew away from it.
+func newSkewedServer(t *testing.T, skew time.Duration) *httptest.Server {
+	var adjustable atomic.Int64
+	adjustable.Store(int64(skew))
+	return newAdjustableSkewedServer(t, &adjustable)
+}
+
+// newAdjustableSkewedServer is like newSkewedServer but reads the skew on every request.
+func newAdjustableSkewedServer(t *testing.T, skew *atomic.Int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().Add(time.Duration(skew.Load()))
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		signed, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		if d := now.Sub(signed); d > maxClockSkew || d < -maxClockSkew {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"__type":  "InvalidSignatureException",
+				"message": "Signature expired: " + r.Header.Get("X-Amz-Date") + " is now earlier than the server time",
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"authorizationData": []map[string]any{{
+				"authorizationToken": "QVdTOnBhc3N3b3Jk",
+				"expiresAt":          now.Add(12 * time.Hour).Unix(),
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestKeychainClockSkew(t *testing.T) {
+	t.Parallel()
+	server := newSkewedServer(t, time.Hour)
+	keychain := NewKeychain(aws.Config{
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+	}, WithBaseEndpoint(server.URL))
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+}
+
+func TestKeychainClockSkewCorrected(t *testing.T) {
+	t.Parallel()
+	var skew atomic.Int64
+	skew.Store(int64(time.Hour))
+	server := newAdjustableSkewedServer(t, &skew)
+	keychain := NewKeychain(aws.Config{
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+	}, WithBaseEndpoint(server.URL))
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	_, err = authenticator.Authorization()
+	require.NoError(t, err)
+
+	// Once the host clock is fixed the stored offset is rejected in turn and must be reset rather than kept.
+	skew.Store(0)
+	authenticator.(invalidator).invalidate()
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+}
+
+func TestIsClockSkewError(t *testing.T) {
+	t.Parallel()
+	server := newSkewedServer(t, time.Hour)
+	cfg := aws.Config{
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+	}
+	client := NewKeychain(cfg, WithBaseEndpoint(server.URL)).(*ecrKeychain).newClient(cfg, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	_, err := newAuthenticator(client, newOptions(nil)).Authorization()
+	assert.True(t, isClockSkewError(err))
+	date, ok := serverTime(err)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), date, time.Minute)
+}
@@ -0,0 +1,62 @@
+package ecr
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// contextKeychain is implemented by keychains whose Resolve can be governed by a context, such as the Keychain of this package.
+type contextKeychain interface {
+	ResolveContext(ctx context.Context, resource authn.Resource) (authn.Authenticator, error)
+}
+
+// contextAuthenticator is implemented by authenticators whose Authorization can be governed by a context, such as the authenticators of this package.
+type contextAuthenticator interface {
+	AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error)
+}
+
+// resolveContext resolves resource with keychain using ctx if keychain supports it.
+func resolveContext(ctx context.Context, keychain authn.Keychain, resource authn.Resource) (authn.Authenticator, error) {
+	if keychain, ok := keychain.(contextKeychain); ok {
+		return keychain.ResolveContext(ctx, resource)
+	}
+	return keychain.Resolve(resource)
+}
+
+// authorizationContext returns the credentials of authenticator using ctx if authenticator supports it.
+func authorizationContext(ctx context.Context, authenticator authn.Authenticator) (*authn.AuthConfig, error) {
+	if authenticator, ok := authenticator.(contextAuthenticator); ok {
+		return authenticator.AuthorizationContext(ctx)
+	}
+	return authenticator.Authorization()
+}
+
+// defaultContexter is implemented by the keychains and authenticators of this package to return the context of WithBaseContext.
+type defaultContexter interface {
+	defaultContext() context.Context
+}
+
+// defaultContext returns the context of WithBaseContext of v if it is a keychain or authenticator of this package, context.Background otherwise.
+func defaultContext(v any) context.Context {
+	if v, ok := v.(defaultContexter); ok {
+		return v.defaultContext()
+	}
+	return context.Background()
+}
+
+func (keychain *ecrKeychain) defaultContext() context.Context {
+	return keychain.opts.baseContext
+}
+
+func (authenticator *ecrAuthenticator) defaultContext() context.Context {
+	return authenticator.opts.baseContext
+}
+
+func (fallback *anonymousFallback) defaultContext() context.Context {
+	return fallback.baseContext
+}
+
+func (authenticator *middlewareAuthenticator) defaultContext() context.Context {
+	return defaultContext(authenticator.inner)
+}
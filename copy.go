@@ -0,0 +1,38 @@
+package ecr
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Copy streams the image or image index src to dst without storing it locally, authenticating with the package-level keychain of Resolve.
+// Each side is authenticated separately so src and dst can be ECR registries in different regions or accounts, options apply to both.
+func Copy(ctx context.Context, src, dst string, options ...remote.Option) error {
+	srcRef, err := name.ParseReference(src)
+	if err != nil {
+		return err
+	}
+	dstRef, err := name.ParseReference(dst)
+	if err != nil {
+		return err
+	}
+	options = remoteOptions(ctx, getDefaultKeychain(), options)
+	desc, err := remote.Get(srcRef, options...)
+	if err != nil {
+		return err
+	}
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return remote.WriteIndex(dstRef, index, options...)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return remote.Write(dstRef, img, options...)
+}
@@ -0,0 +1,65 @@
+package ecr
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+	src := httptest.NewServer(registry.New())
+	t.Cleanup(src.Close)
+	dst := httptest.NewServer(registry.New())
+	t.Cleanup(dst.Close)
+	host := func(server *httptest.Server) string {
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	index, err := random.Index(1024, 1, 2)
+	require.NoError(t, err)
+	tests := map[string]struct {
+		write  func(ref name.Reference) error
+		digest func() (v1.Hash, error)
+	}{
+		"image": {
+			write:  func(ref name.Reference) error { return remote.Write(ref, img) },
+			digest: img.Digest,
+		},
+		"index": {
+			write:  func(ref name.Reference) error { return remote.WriteIndex(ref, index) },
+			digest: index.Digest,
+		},
+	}
+	for kind, tt := range tests {
+		kind, tt := kind, tt
+		t.Run(kind, func(t *testing.T) {
+			t.Parallel()
+			srcRef := host(src) + "/team/" + kind + ":latest"
+			dstRef := host(dst) + "/promoted/" + kind + ":latest"
+			parsed, err := name.ParseReference(srcRef)
+			require.NoError(t, err)
+			require.NoError(t, tt.write(parsed))
+
+			require.NoError(t, Copy(context.Background(), srcRef, dstRef))
+			parsed, err = name.ParseReference(dstRef)
+			require.NoError(t, err)
+			desc, err := remote.Head(parsed)
+			require.NoError(t, err)
+			expected, err := tt.digest()
+			require.NoError(t, err)
+			assert.Equal(t, expected, desc.Digest)
+		})
+	}
+}
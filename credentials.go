@@ -0,0 +1,75 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// HostCredentials returns the username and password keychain resolves for the registry host along with when they expire, zero if unknown.
+// This allows clients that are not built on go-containerregistry to reuse the cached ECR tokens,
+// such as regclient by populating the User and Pass of its config.Host and refreshing it before expiresAt.
+// The AWS calls made are governed by the context of WithBaseContext.
+func HostCredentials(keychain authn.Keychain, host string) (username, password string, expiresAt time.Time, err error) {
+	authenticator, err := resolveContext(defaultContext(keychain), keychain, registryResource(host))
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	authConfig, expiresAt, err := authorization(defaultContext(authenticator), authenticator)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return authConfig.Username, authConfig.Password, expiresAt, nil
+}
+
+// ExecCredential is a stable JSON document of the credentials of a registry for generic exec credential integrations,
+// such as Nomad templates or custom agents that do not speak the docker credential helper protocol.
+type ExecCredential struct {
+	Username   string     `json:"username"`
+	Password   string     `json:"password"`
+	Registry   string     `json:"registry"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// NewExecCredential returns the ExecCredential of the credentials keychain resolves for registry, with no Expiration if unknown.
+func NewExecCredential(keychain authn.Keychain, registry string) (*ExecCredential, error) {
+	username, password, expiresAt, err := HostCredentials(keychain, registry)
+	if err != nil {
+		return nil, err
+	}
+	credential := &ExecCredential{Username: username, Password: password, Registry: registry}
+	if !expiresAt.IsZero() {
+		credential.Expiration = &expiresAt
+	}
+	return credential, nil
+}
+
+// WriteCredentials writes the credentials keychain resolves for registry to w as username:password,
+// the output argocd-image-updater expects from the scripts of its ext: credentials.
+func WriteCredentials(w io.Writer, keychain authn.Keychain, registry string) error {
+	username, password, _, err := HostCredentials(keychain, registry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s:%s\n", username, password)
+	return err
+}
+
+// Lease returns the credentials of registry along with when they will be refreshed, zero if they never expire.
+func (keychain *ecrKeychain) Lease(ctx context.Context, registry string) (authn.AuthConfig, time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return authn.AuthConfig{}, time.Time{}, err
+	}
+	authenticator, err := keychain.ResolveContext(ctx, registryResource(registry))
+	if err != nil {
+		return authn.AuthConfig{}, time.Time{}, err
+	}
+	authConfig, expiresAt, err := authorization(ctx, authenticator)
+	if err != nil {
+		return authn.AuthConfig{}, time.Time{}, err
+	}
+	return *authConfig, expiresAt, nil
+}
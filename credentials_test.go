@@ -0,0 +1,127 @@
+package ecr
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostCredentials(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	username, password, expiresAt, err := HostCredentials(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, "AWS", username)
+	assert.Equal(t, "password", password)
+	assert.Equal(t, client.expiresAt.Add(-DefaultEarlyExpiry), expiresAt)
+
+	username, password, expiresAt, err = HostCredentials(keychain, "index.docker.io")
+	require.NoError(t, err)
+	assert.Empty(t, username)
+	assert.Empty(t, password)
+	assert.True(t, expiresAt.IsZero())
+}
+
+func TestHostCredentialsBaseContext(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	var value any
+	keychain := newTestKeychain("us-west-2/false", funcGetAuthorizationToken(func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+		value = ctx.Value(contextKey{})
+		return client.GetAuthorizationToken(ctx, params, optFns...)
+	}), WithBaseContext(context.WithValue(context.Background(), contextKey{}, "base")))
+	_, _, _, err := HostCredentials(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, "base", value)
+}
+
+func TestNewExecCredential(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	credential, err := NewExecCredential(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, &ExecCredential{
+		Username:   "AWS",
+		Password:   "password",
+		Registry:   "123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		Expiration: aws.Time(client.expiresAt.Add(-DefaultEarlyExpiry)),
+	}, credential)
+
+	credential, err = NewExecCredential(keychain, "index.docker.io")
+	require.NoError(t, err)
+	b, err := json.Marshal(credential)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"username":"","password":"","registry":"index.docker.io"}`, string(b))
+}
+
+func TestWriteCredentials(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	var b strings.Builder
+	require.NoError(t, WriteCredentials(&b, keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, WriteCredentials(&b, keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	assert.Equal(t, "AWS:password\nAWS:password\n", b.String())
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestKeychainLease(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	authConfig, expiresAt, err := keychain.Lease(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, authn.AuthConfig{Username: "AWS", Password: "password"}, authConfig)
+	assert.Equal(t, client.expiresAt.Add(-DefaultEarlyExpiry), expiresAt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = keychain.Lease(ctx, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// contextKey is a context key recording which ctx reached an authenticator.
+type contextKey struct{}
+
+// contextRecorder is a middleware authenticator recording the ctx it is called with.
+type contextRecorder struct {
+	authn.Authenticator
+	values *[]any
+}
+
+func (recorder contextRecorder) AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) {
+	*recorder.values = append(*recorder.values, ctx.Value(contextKey{}))
+	return authorizationContext(ctx, recorder.Authenticator)
+}
+
+func TestKeychainLeaseMiddleware(t *testing.T) {
+	t.Parallel()
+	var values []any
+	keychain := NewKeychain(aws.Config{Region: "us-west-2"}, WithAuthenticatorMiddleware(func(next authn.Authenticator) authn.Authenticator {
+		return contextRecorder{Authenticator: next, values: &values}
+	})).(*ecrKeychain)
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := keychain.newAuthenticator(aws.Config{}, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"), "us-west-2/false", keychain.opts.logger)
+	authenticator.(*middlewareAuthenticator).inner.(*ecrAuthenticator).client = client
+	entry := &keychainEntry{authenticator: authenticator}
+	entry.once.Do(entry.publish)
+	keychain.cache.Load().Store("us-west-2/false", entry)
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "lease")
+	authConfig, expiresAt, err := keychain.Lease(ctx, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+	assert.Equal(t, client.expiresAt.Add(-DefaultEarlyExpiry), expiresAt)
+	assert.Equal(t, []any{"lease"}, values)
+	assert.EqualValues(t, 1, client.calls.Load())
+}
@@ -0,0 +1,75 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// CrossAccountOptions configures NewCrossAccountDockerConfig.
+type CrossAccountOptions struct {
+	// AccountIDs are the accounts whose registries are included.
+	AccountIDs []string
+	// RoleName is the IAM role assumed in every account, such as ecr-push, it must trust the credentials of the aws.Config.
+	RoleName string
+	// SessionName is the role session name recorded in CloudTrail, generated by the SDK if empty.
+	SessionName string
+	// Regions are the regions of the registries of every account, the region of the aws.Config if empty.
+	Regions []string
+}
+
+// NewCrossAccountDockerConfig assumes RoleName in each account and returns a single DockerConfig with the credentials of the registries of every account and region,
+// along with the earliest time they expire. It is intended for central build systems pushing to many product accounts.
+func NewCrossAccountDockerConfig(ctx context.Context, cfg aws.Config, accounts CrossAccountOptions, opts ...Option) (*DockerConfig, time.Time, error) {
+	registries, roles, err := accounts.resolve(cfg.Region)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	opts = opts[:len(opts):len(opts)]
+	for accountID, role := range roles {
+		opts = append(opts, WithAssumeRole(accountID, role))
+	}
+	keychain := NewKeychain(cfg, opts...)
+	// Mint the tokens concurrently, NewDockerConfig then reads them from the cache.
+	if err := keychain.Warm(ctx, registries...); err != nil {
+		return nil, time.Time{}, err
+	}
+	return NewDockerConfig(keychain, registries...)
+}
+
+// resolve returns the registry hostnames of every account and region along with the role to assume in each account.
+func (accounts CrossAccountOptions) resolve(defaultRegion string) ([]string, map[string]AssumeRole, error) {
+	regions := accounts.Regions
+	if len(regions) == 0 {
+		regions = []string{defaultRegion}
+	}
+	if len(accounts.AccountIDs) == 0 || regions[0] == "" {
+		return nil, nil, errors.New("at least one account ID and region are required")
+	}
+	if accounts.RoleName == "" {
+		return nil, nil, errors.New("a role name is required")
+	}
+	partition := "aws"
+	if p, ok := partitionFor(regions[0], ""); ok {
+		partition = p.Name
+	}
+	registries := make([]string, 0, len(accounts.AccountIDs)*len(regions))
+	roles := make(map[string]AssumeRole, len(accounts.AccountIDs))
+	for _, accountID := range accounts.AccountIDs {
+		for _, region := range regions {
+			reg := &Registry{AccountID: accountID, Region: region, DNSSuffix: dnsSuffixForRegion(region)}
+			if ParseStrict(reg.String()) == nil {
+				return nil, nil, fmt.Errorf("account ID %q or region %q is invalid", accountID, region)
+			}
+			registries = append(registries, reg.String())
+		}
+		roles[accountID] = AssumeRole{
+			RoleARN:     "arn:" + partition + ":iam::" + accountID + ":role/" + accounts.RoleName,
+			SessionName: accounts.SessionName,
+		}
+	}
+	return registries, roles, nil
+}
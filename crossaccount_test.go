@@ -0,0 +1,103 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossAccountOptions(t *testing.T) {
+	t.Parallel()
+	accounts := CrossAccountOptions{
+		AccountIDs:  []string{"123456789012", "210987654321"},
+		RoleName:    "ecr-push",
+		SessionName: "ci",
+		Regions:     []string{"us-west-2", "eu-west-1"},
+	}
+	registries, roles, err := accounts.resolve("us-east-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+		"210987654321.dkr.ecr.us-west-2.amazonaws.com",
+		"210987654321.dkr.ecr.eu-west-1.amazonaws.com",
+	}, registries)
+	assert.Equal(t, map[string]AssumeRole{
+		"123456789012": {RoleARN: "arn:aws:iam::123456789012:role/ecr-push", SessionName: "ci"},
+		"210987654321": {RoleARN: "arn:aws:iam::210987654321:role/ecr-push", SessionName: "ci"},
+	}, roles)
+
+	registries, roles, err = CrossAccountOptions{AccountIDs: []string{"123456789012"}, RoleName: "ecr-push"}.resolve("cn-north-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn"}, registries)
+	assert.Equal(t, "arn:aws-cn:iam::123456789012:role/ecr-push", roles["123456789012"].RoleARN)
+
+	_, _, err = CrossAccountOptions{AccountIDs: []string{"1234"}, RoleName: "ecr-push"}.resolve("us-west-2")
+	assert.Error(t, err)
+	_, _, err = CrossAccountOptions{AccountIDs: []string{"123456789012"}}.resolve("us-west-2")
+	assert.Error(t, err)
+	_, _, err = CrossAccountOptions{AccountIDs: []string{"123456789012"}, RoleName: "ecr-push"}.resolve("")
+	assert.Error(t, err)
+}
+
+func TestNewCrossAccountDockerConfig(t *testing.T) {
+	t.Parallel()
+	stsServer := newSTSServer(t)
+	var mu sync.Mutex
+	var signedBy []string
+	ecrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		signedBy = append(signedBy, strings.SplitN(strings.SplitN(r.Header.Get("Authorization"), "Credential=", 2)[1], "/", 2)[0])
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"authorizationData": []map[string]any{{
+				"authorizationToken": "QVdTOnBhc3N3b3Jk",
+				"expiresAt":          time.Now().Add(12 * time.Hour).Unix(),
+			}},
+		})
+	}))
+	t.Cleanup(ecrServer.Close)
+
+	dockerConfig, expiresAt, err := NewCrossAccountDockerConfig(context.Background(), aws.Config{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(stsServer.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIABASE", "secret", ""),
+	}, CrossAccountOptions{
+		AccountIDs:  []string{"123456789012", "210987654321"},
+		RoleName:    "ecr-push",
+		SessionName: "ci",
+		Regions:     []string{"us-west-2", "eu-west-1"},
+	}, WithBaseEndpoint(ecrServer.URL))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(12*time.Hour-DefaultEarlyExpiry), expiresAt, time.Minute)
+
+	var roleARNs []string
+	for _, request := range stsServer.Requests() {
+		assert.Equal(t, "AssumeRole", request.PostForm.Get("Action"))
+		assert.Equal(t, "ci", request.PostForm.Get("RoleSessionName"))
+		roleARNs = append(roleARNs, request.PostForm.Get("RoleArn"))
+	}
+	assert.ElementsMatch(t, []string{"arn:aws:iam::123456789012:role/ecr-push", "arn:aws:iam::210987654321:role/ecr-push"}, roleARNs)
+	assert.Equal(t, []string{"AKIAROLE", "AKIAROLE", "AKIAROLE", "AKIAROLE"}, signedBy)
+
+	auth := DockerConfigAuth{Auth: base64.StdEncoding.EncodeToString([]byte("AWS:password"))}
+	assert.Equal(t, map[string]DockerConfigAuth{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com": auth,
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com": auth,
+		"210987654321.dkr.ecr.us-west-2.amazonaws.com": auth,
+		"210987654321.dkr.ecr.eu-west-1.amazonaws.com": auth,
+	}, dockerConfig.Auths)
+}
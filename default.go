@@ -0,0 +1,36 @@
+package ecr
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+var (
+	defaultKeychainMu sync.Mutex
+	defaultKeychain   authn.Keychain
+)
+
+// SetDefaultKeychain replaces the keychain used by Resolve, such as with a keychain using custom options.
+func SetDefaultKeychain(keychain authn.Keychain) {
+	defaultKeychainMu.Lock()
+	defer defaultKeychainMu.Unlock()
+	defaultKeychain = keychain
+}
+
+// getDefaultKeychain returns the keychain of SetDefaultKeychain, falling back to the LazyDefaultKeychain of AmbientKeychain.
+func getDefaultKeychain() authn.Keychain {
+	defaultKeychainMu.Lock()
+	defer defaultKeychainMu.Unlock()
+	if defaultKeychain == nil {
+		return lazyDefaultKeychain
+	}
+	return defaultKeychain
+}
+
+// Resolve returns the authenticator of registry using the package-level LazyDefaultKeychain of AmbientKeychain, or the keychain of SetDefaultKeychain,
+// for small tools that don't want to pass a keychain around.
+func Resolve(ctx context.Context, registry string) (authn.Authenticator, error) {
+	return resolveContext(ctx, getDefaultKeychain(), registryResource(registry))
+}
@@ -0,0 +1,27 @@
+package ecr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	t.Cleanup(func() { SetDefaultKeychain(nil) })
+	authenticator, err := Resolve(context.Background(), "index.docker.io")
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+	assert.Same(t, lazyDefaultKeychain, getDefaultKeychain())
+
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour))
+	SetDefaultKeychain(keychain)
+	authenticator, err = Resolve(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+}
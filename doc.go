@@ -0,0 +1,12 @@
+// Package ecr implements an authn.Keychain for AWS Elastic Container Registry (ECR) that caches authorization tokens until they expire.
+//
+// The keychain can be used anywhere go-containerregistry accepts an authn.Keychain or remote.Option, for example with crane:
+//
+//	crane.Pull(ref, crane.WithAuthFromKeychain(ecr.AmbientKeychain))
+//
+// Or when signing and verifying images with cosign, where every signature, attestation and SBOM lookup would otherwise call GetAuthorizationToken:
+//
+//	ociremote.WithRemoteOptions(ecr.RemoteOptions(keychain)...)
+//
+// ECR tokens are basic credentials, the authn.AuthConfig returned never has an IdentityToken or RegistryToken set so clients never attempt an OAuth2 refresh token exchange with ECR.
+package ecr
@@ -0,0 +1,154 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// DockerConfig is a docker config.json with credentials embedded in its auths rather than delegated to a credential helper.
+type DockerConfig struct {
+	Auths map[string]DockerConfigAuth `json:"auths"`
+}
+
+// DockerConfigAuth is the embedded credentials of a registry in a DockerConfig.
+type DockerConfigAuth struct {
+	Auth string `json:"auth"`
+}
+
+// NewDockerConfig returns a DockerConfig with the credentials keychain resolves for registries along with the earliest time they expire, zero if unknown.
+// Registries keychain resolves anonymously are omitted. This is intended for CI systems where mounting a file is easier than installing a credential helper.
+func NewDockerConfig(keychain authn.Keychain, registries ...string) (*DockerConfig, time.Time, error) {
+	dockerConfig := &DockerConfig{Auths: make(map[string]DockerConfigAuth, len(registries))}
+	var earliest time.Time
+	for _, registry := range registries {
+		username, password, expiresAt, err := HostCredentials(keychain, registry)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if username == "" && password == "" {
+			continue
+		}
+		dockerConfig.Auths[registry] = DockerConfigAuth{
+			Auth: base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+		}
+		if !expiresAt.IsZero() && (earliest.IsZero() || expiresAt.Before(earliest)) {
+			earliest = expiresAt
+		}
+	}
+	return dockerConfig, earliest, nil
+}
+
+// DefaultAuthFile returns the containers auth.json used by Podman and Buildah, ${XDG_RUNTIME_DIR}/containers/auth.json
+// or ${HOME}/.config/containers/auth.json if XDG_RUNTIME_DIR is not set.
+func DefaultAuthFile() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "containers", "auth.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "containers", "auth.json"), nil
+}
+
+// WriteFile atomically merges the auths of c into the docker config.json or containers auth.json at path, creating it if it does not exist.
+// The auths of other registries and any other settings in the file are preserved.
+func (c *DockerConfig) WriteFile(path string) error {
+	file := make(map[string]json.RawMessage)
+	auths := make(map[string]json.RawMessage)
+	if b, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, &file); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if raw, ok := file["auths"]; ok {
+			if err := json.Unmarshal(raw, &auths); err != nil {
+				return fmt.Errorf("failed to parse auths of %s: %w", path, err)
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for registry, auth := range c.Auths {
+		raw, err := json.Marshal(auth)
+		if err != nil {
+			return err
+		}
+		auths[registry] = raw
+	}
+	raw, err := json.Marshal(auths)
+	if err != nil {
+		return err
+	}
+	file["auths"] = raw
+	b, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// writeFileAtomic writes b to a temporary file next to path and renames it over path, so readers never observe a partial file.
+func writeFileAtomic(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// DefaultDockerConfigRefreshInterval is how often RefreshDockerConfig rewrites the file when the expiry of the credentials is unknown.
+var DefaultDockerConfigRefreshInterval = time.Hour
+
+// RefreshDockerConfig writes the DockerConfig of registries to path and rewrites it each time the earliest credentials expire until ctx is done,
+// covering builds such as kaniko that read the file at arbitrary times. If the expiry is unknown it is rewritten every DefaultDockerConfigRefreshInterval.
+func RefreshDockerConfig(ctx context.Context, keychain authn.Keychain, path string, registries ...string) error {
+	return SyncDockerConfig(ctx, keychain, path, 0, registries...)
+}
+
+// SyncDockerConfig is RefreshDockerConfig rewriting the file at least every interval, or only at expiry if interval is not positive.
+// It is intended to run as a sidecar keeping a shared config.json fresh for tools that only read static files, such as older Jenkins agents.
+func SyncDockerConfig(ctx context.Context, keychain authn.Keychain, path string, interval time.Duration, registries ...string) error {
+	for {
+		dockerConfig, expiresAt, err := NewDockerConfig(keychain, registries...)
+		if err != nil {
+			return err
+		}
+		if err := dockerConfig.WriteFile(path); err != nil {
+			return err
+		}
+		wait := DefaultDockerConfigRefreshInterval
+		if !expiresAt.IsZero() {
+			wait = max(time.Until(expiresAt), time.Second)
+		}
+		if interval > 0 {
+			wait = min(wait, interval)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
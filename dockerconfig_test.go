@@ -0,0 +1,100 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDockerConfig(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	dockerConfig, expiresAt, err := NewDockerConfig(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com", "index.docker.io")
+	require.NoError(t, err)
+	assert.Equal(t, client.expiresAt.Add(-DefaultEarlyExpiry), expiresAt)
+	b, err := json.Marshal(dockerConfig)
+	require.NoError(t, err)
+	auth := base64.StdEncoding.EncodeToString([]byte("AWS:password"))
+	assert.JSONEq(t, `{"auths":{"123456789012.dkr.ecr.us-west-2.amazonaws.com":{"auth":"`+auth+`"}}}`, string(b))
+}
+
+func TestDockerConfigWriteFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "containers", "auth.json")
+	dockerConfig := &DockerConfig{Auths: map[string]DockerConfigAuth{"123456789012.dkr.ecr.us-west-2.amazonaws.com": {Auth: "bmV3"}}}
+	require.NoError(t, dockerConfig.WriteFile(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o600), info.Mode().Perm())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"auths":{"quay.io":{"auth":"cXVheQ=="},"123456789012.dkr.ecr.us-west-2.amazonaws.com":{"auth":"b2xk"}},"credHelpers":{"ghcr.io":"gh"}}`), 0o600))
+	require.NoError(t, dockerConfig.WriteFile(path))
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"auths":{"quay.io":{"auth":"cXVheQ=="},"123456789012.dkr.ecr.us-west-2.amazonaws.com":{"auth":"bmV3"}},"credHelpers":{"ghcr.io":"gh"}}`, string(b))
+}
+
+func TestDefaultAuthFile(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	path, err := DefaultAuthFile()
+	require.NoError(t, err)
+	assert.Equal(t, "/run/user/1000/containers/auth.json", path)
+}
+
+func TestRefreshDockerConfig(t *testing.T) {
+	t.Parallel()
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour))
+	path := filepath.Join(t.TempDir(), "config.json")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := RefreshDockerConfig(ctx, keychain, path, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Credentials of unknown expiry are still refreshed until ctx is done.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = RefreshDockerConfig(ctx, keychain, path, "index.docker.io")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSyncDockerConfig(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	path := filepath.Join(t.TempDir(), "config.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- SyncDockerConfig(ctx, keychain, path, 10*time.Millisecond, "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	}()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, time.Millisecond)
+	require.NoError(t, os.Remove(path))
+
+	// The file is rewritten after interval even though the credentials are far from expiry.
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.EqualValues(t, 1, client.calls.Load())
+}
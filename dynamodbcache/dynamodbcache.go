@@ -0,0 +1,100 @@
+// Package dynamodbcache implements an ecr.Cache backed by a DynamoDB table so a fleet of runners can share ECR tokens.
+package dynamodbcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+)
+
+// Client is the subset of *dynamodb.Client used by Cache.
+type Client interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// Cache implements ecr.Cache storing each token as an item of a DynamoDB table.
+// The partition key of the table must be the string attribute "key", enabling TTL on the "ttl" attribute removes expired tokens.
+type Cache struct {
+	client Client
+	table  string
+	now    func() time.Time
+}
+
+// New returns a Cache storing tokens in table using client.
+func New(client Client, table string) *Cache {
+	return &Cache{client: client, table: table, now: time.Now}
+}
+
+// Get returns the token stored under key, nil if there is none or it has expired but was not yet removed by TTL.
+func (c *Cache) Get(ctx context.Context, key string) (*ecr.CachedToken, error) {
+	out, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.table),
+		Key:            map[string]types.AttributeValue{"key": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("(*dynamodb.Client).GetItem failed: %w", err)
+	} else if out.Item == nil {
+		return nil, nil
+	}
+	token, ok := out.Item["token"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("(*dynamodb.Client).GetItem returned an item without a token")
+	}
+	expiresAt, ok := out.Item["expires_at"].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, errors.New("(*dynamodb.Client).GetItem returned an item without an expires_at")
+	}
+	unix, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("(*dynamodb.Client).GetItem returned an invalid expires_at: %w", err)
+	}
+	cached := &ecr.CachedToken{Token: token.Value, ExpiresAt: time.Unix(unix, 0)}
+	if refreshAt, ok := out.Item["refresh_at"].(*types.AttributeValueMemberN); ok {
+		unix, err := strconv.ParseInt(refreshAt.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("(*dynamodb.Client).GetItem returned an invalid refresh_at: %w", err)
+		}
+		cached.RefreshAt = time.Unix(unix, 0)
+	}
+	if !c.now().Before(cached.ExpiresAt) {
+		return nil, nil
+	}
+	return cached, nil
+}
+
+// Put stores token under key with a conditional write, so a token never replaces one that expires later stored concurrently by another runner.
+func (c *Cache) Put(ctx context.Context, key string, token *ecr.CachedToken) error {
+	expiresAt := &types.AttributeValueMemberN{Value: strconv.FormatInt(token.ExpiresAt.Unix(), 10)}
+	item := map[string]types.AttributeValue{
+		"key":        &types.AttributeValueMemberS{Value: key},
+		"token":      &types.AttributeValueMemberS{Value: token.Token},
+		"expires_at": expiresAt,
+		"ttl":        expiresAt,
+	}
+	if !token.RefreshAt.IsZero() {
+		item["refresh_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(token.RefreshAt.Unix(), 10)}
+	}
+	_, err := c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(c.table),
+		Item:                      item,
+		ConditionExpression:       aws.String("attribute_not_exists(#key) OR expires_at < :expires_at"),
+		ExpressionAttributeNames:  map[string]string{"#key": "key"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":expires_at": expiresAt},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("(*dynamodb.Client).PutItem failed: %w", err)
+	}
+	return nil
+}
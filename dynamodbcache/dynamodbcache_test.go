@@ -0,0 +1,66 @@
+package dynamodbcache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient implements Client with an in-memory table, evaluating the condition of Put.
+type fakeClient struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func (c *fakeClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: c.items[params.Key["key"].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (c *fakeClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := params.Item["key"].(*types.AttributeValueMemberS).Value
+	if existing, ok := c.items[key]; ok {
+		current, _ := strconv.Atoi(existing["expires_at"].(*types.AttributeValueMemberN).Value)
+		next, _ := strconv.Atoi(params.ExpressionAttributeValues[":expires_at"].(*types.AttributeValueMemberN).Value)
+		if current >= next {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+	}
+	c.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1700000000, 0)
+	cache := New(&fakeClient{items: make(map[string]map[string]types.AttributeValue)}, "ecr-tokens")
+	cache.now = func() time.Time { return now }
+
+	token, err := cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	fresh := &ecr.CachedToken{Token: "QVdTOnBhc3N3b3Jk", ExpiresAt: now.Add(12 * time.Hour), RefreshAt: now.Add(6 * time.Hour)}
+	require.NoError(t, cache.Put(context.Background(), "ci/us-west-2/false", fresh))
+	require.NoError(t, cache.Put(context.Background(), "ci/us-west-2/false", &ecr.CachedToken{Token: "b2xk", ExpiresAt: now.Add(time.Hour)}))
+	token, err = cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Equal(t, fresh, token)
+
+	now = now.Add(12 * time.Hour)
+	token, err = cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+}
@@ -0,0 +1,130 @@
+// Package ecrtest provides fakes for unit-testing code that pulls from or pushes to ECR without AWS.
+package ecrtest
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Token is a scripted ECR authorization token, if Err is set it is returned instead.
+type Token struct {
+	Username  string
+	Password  string
+	ExpiresAt time.Time
+	Err       error
+}
+
+// NewToken returns a Token for the AWS user that expires after lifetime.
+func NewToken(password string, lifetime time.Duration) Token {
+	return Token{Username: "AWS", Password: password, ExpiresAt: time.Now().Add(lifetime)}
+}
+
+// encoded returns the base64 encoded authorization token as returned by ECR.
+func (token Token) encoded() string {
+	return base64.StdEncoding.EncodeToString([]byte(token.Username + ":" + token.Password))
+}
+
+// script returns the scripted tokens in order, repeating the last one once exhausted.
+type script struct {
+	mu     sync.Mutex
+	tokens []Token
+	calls  int
+}
+
+func (s *script) next() Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.tokens) == 0 {
+		return Token{}
+	}
+	token := s.tokens[0]
+	if len(s.tokens) > 1 {
+		s.tokens = s.tokens[1:]
+	}
+	return token
+}
+
+// Calls returns how many tokens have been requested.
+func (s *script) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// TokenFunc returns scripted tokens with the signature of (*ecr.Client).GetAuthorizationToken.
+// It can be passed to ecr.NewAuthenticatorFromTokenFunc to exercise the caching and expiry logic.
+type TokenFunc struct {
+	script
+}
+
+// NewTokenFunc returns a TokenFunc returning the given tokens in order, repeating the last one.
+func NewTokenFunc(tokens ...Token) *TokenFunc {
+	return &TokenFunc{script: script{tokens: tokens}}
+}
+
+// GetAuthorizationToken returns the next scripted token.
+func (fn *TokenFunc) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	token := fn.next()
+	if token.Err != nil {
+		return nil, token.Err
+	}
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []types.AuthorizationData{{
+			AuthorizationToken: aws.String(token.encoded()),
+			ExpiresAt:          aws.Time(token.ExpiresAt),
+		}},
+	}, nil
+}
+
+// Authenticator is a fake authn.Authenticator returning scripted tokens in order, repeating the last one.
+// Like the authenticators of the ecr package a token is reused until its ExpiresAt has passed, the next one is then returned.
+type Authenticator struct {
+	script
+	// Now is the clock ExpiresAt is compared against, time.Now if nil.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	current *Token
+}
+
+// NewAuthenticator returns an Authenticator returning the given tokens in order, repeating the last one.
+func NewAuthenticator(tokens ...Token) *Authenticator {
+	return &Authenticator{script: script{tokens: tokens}}
+}
+
+// Authorization returns the current token, or the next scripted token if it has expired.
+func (a *Authenticator) Authorization() (*authn.AuthConfig, error) {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.current == nil || !now().Before(a.current.ExpiresAt) {
+		token := a.next()
+		if token.Err != nil {
+			return nil, token.Err
+		}
+		a.current = &token
+	}
+	return &authn.AuthConfig{Username: a.current.Username, Password: a.current.Password}, nil
+}
+
+// Keychain is a fake authn.Keychain resolving registries to fixed authenticators and anything else to authn.Anonymous.
+type Keychain map[string]authn.Authenticator
+
+// Resolve returns the authenticator for the resource's registry.
+func (keychain Keychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	if authenticator, ok := keychain[resource.RegistryStr()]; ok {
+		return authenticator, nil
+	}
+	return authn.Anonymous, nil
+}
@@ -0,0 +1,87 @@
+package ecrtest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+	"github.com/bored-engineer/docker-credential-ecr/ecrtest"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+	server := ecrtest.NewServer(ecrtest.NewToken("first", 12*time.Hour))
+	defer server.Close()
+	keychain := ecr.NewKeychain(server.Config())
+	reg, err := name.NewRegistry("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		authenticator, err := keychain.Resolve(reg)
+		require.NoError(t, err)
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "AWS", authConfig.Username)
+		assert.Equal(t, "first", authConfig.Password)
+	}
+	assert.Equal(t, 1, server.Calls())
+}
+
+func TestServerError(t *testing.T) {
+	t.Parallel()
+	server := ecrtest.NewServer(ecrtest.Token{Err: errors.New("boom")})
+	defer server.Close()
+	authenticator, err := ecr.NewKeychain(server.Config()).Resolve(name.MustParseReference("123456789012.dkr.ecr.us-west-2.amazonaws.com/app").Context())
+	require.NoError(t, err)
+	_, err = authenticator.Authorization()
+	assert.ErrorContains(t, err, "boom")
+}
+
+func TestTokenFunc(t *testing.T) {
+	t.Parallel()
+	fn := ecrtest.NewTokenFunc(ecrtest.NewToken("expired", time.Minute), ecrtest.NewToken("fresh", 12*time.Hour))
+	authenticator := ecr.NewAuthenticatorFromTokenFunc(fn.GetAuthorizationToken, 15*time.Minute)
+	for _, expected := range []string{"expired", "fresh", "fresh"} {
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, expected, authConfig.Password)
+	}
+	assert.Equal(t, 2, fn.Calls())
+}
+
+func TestAuthenticator(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	fake := ecrtest.NewAuthenticator(
+		ecrtest.Token{Username: "AWS", Password: "first", ExpiresAt: now.Add(time.Hour)},
+		ecrtest.Token{Username: "AWS", Password: "second", ExpiresAt: now.Add(2 * time.Hour)},
+	)
+	fake.Now = func() time.Time { return now }
+	for _, step := range []struct {
+		offset   time.Duration
+		expected string
+	}{{0, "first"}, {time.Minute, "first"}, {time.Hour, "second"}, {90 * time.Minute, "second"}} {
+		now = now.Add(step.offset)
+		authConfig, err := fake.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, step.expected, authConfig.Password)
+		now = now.Add(-step.offset)
+	}
+	assert.Equal(t, 2, fake.Calls())
+}
+
+func TestKeychain(t *testing.T) {
+	t.Parallel()
+	fake := ecrtest.NewAuthenticator(ecrtest.NewToken("password", time.Hour))
+	keychain := ecrtest.Keychain{"123456789012.dkr.ecr.us-west-2.amazonaws.com": fake}
+	authenticator, err := keychain.Resolve(name.MustParseReference("123456789012.dkr.ecr.us-west-2.amazonaws.com/app").Context())
+	require.NoError(t, err)
+	assert.Same(t, fake, authenticator)
+	authenticator, err = keychain.Resolve(name.MustParseReference("ubuntu").Context())
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+}
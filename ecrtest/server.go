@@ -0,0 +1,65 @@
+package ecrtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const getAuthorizationTokenTarget = "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"
+
+// Server is an httptest.Server implementing the ECR GetAuthorizationToken API with scripted tokens.
+type Server struct {
+	*httptest.Server
+	script
+}
+
+// NewServer starts a Server returning the given tokens in order, repeating the last one.
+// The caller should call Close when finished to shut it down.
+func NewServer(tokens ...Token) *Server {
+	server := &Server{script: script{tokens: tokens}}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if r.Method != http.MethodPost || r.Header.Get("X-Amz-Target") != getAuthorizationTokenTarget {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "InvalidParameterException",
+			"message": "ecrtest only implements GetAuthorizationToken",
+		})
+		return
+	}
+	token := server.next()
+	if token.Err != nil {
+		// A client error avoids the SDK retrying with backoff, keeping tests fast.
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "InvalidParameterException",
+			"message": token.Err.Error(),
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"authorizationData": []map[string]any{{
+			"authorizationToken": token.encoded(),
+			"expiresAt":          float64(token.ExpiresAt.UnixMilli()) / 1000,
+			"proxyEndpoint":      server.URL,
+		}},
+	})
+}
+
+// Config returns an aws.Config with static credentials whose BaseEndpoint points at the server.
+func (server *Server) Config() aws.Config {
+	return aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAECRTEST", "ecrtest", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+	}
+}
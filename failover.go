@@ -0,0 +1,112 @@
+package ecr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Failover resolves ECR references, rewriting them to a replica region when the ECR API or registry of the requested region is failing.
+type Failover struct {
+	keychain    authn.Keychain
+	replicas    map[string][]string
+	nameOptions []name.Option
+}
+
+// NewFailover returns a Failover using keychain, where replicas maps a region to the regions its repositories are replicated to in order of preference.
+// The name options, such as name.Insecure, are used to construct the references rewritten to a replica region as when parsing the original reference.
+func NewFailover(keychain authn.Keychain, replicas map[string][]string, opts ...name.Option) *Failover {
+	return &Failover{keychain: keychain, replicas: replicas, nameOptions: opts}
+}
+
+// Resolve returns the reference to pull and its authenticator.
+// This is ref itself unless fetching a token for its region fails, in which case the first replica region a token can be fetched for is used.
+// A cached token hides an outage of the registry itself, use Do to also fail over when the registry is unavailable.
+func (failover *Failover) Resolve(ref name.Reference) (name.Reference, authn.Authenticator, error) {
+	var resolved name.Reference
+	var authenticator authn.Authenticator
+	err := failover.Do(ref, func(ref name.Reference, auth authn.Authenticator) error {
+		resolved, authenticator = ref, auth
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resolved, authenticator, nil
+}
+
+// Do calls fn with ref and its authenticator, such as to pull it with remote.Image.
+// If fetching a token fails or fn fails because the registry is unavailable (a 5xx or 429 response or a network error),
+// fn is called again with the reference rewritten to each replica region in turn until it succeeds.
+func (failover *Failover) Do(ref name.Reference, fn func(ref name.Reference, authenticator authn.Authenticator) error) error {
+	retry, err := failover.try(ref, fn)
+	if !retry {
+		return err
+	}
+	reg := Parse(ref.Context().RegistryStr())
+	if reg == nil || reg.DNSSuffix == ecrPublicDomain {
+		return err
+	}
+	errs := []error{err}
+	for _, region := range failover.replicas[reg.Region] {
+		replica := *reg
+		replica.Region = region
+		replicaRef, err := rewriteRepository(ref, replica.String()+"/"+ref.Context().RepositoryStr(), failover.nameOptions...)
+		if err != nil {
+			return err
+		}
+		retry, err := failover.try(replicaRef, fn)
+		if !retry {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// try calls fn with ref and its authenticator, reporting whether a replica region should be tried on failure.
+func (failover *Failover) try(ref name.Reference, fn func(ref name.Reference, authenticator authn.Authenticator) error) (bool, error) {
+	authenticator, err := failover.authorize(ref.Context())
+	if err != nil {
+		return true, err
+	}
+	if err := fn(ref, authenticator); err != nil {
+		return isRegistryUnavailable(err), err
+	}
+	return false, nil
+}
+
+// authorize resolves the authenticator for resource and checks a token can be fetched with it.
+func (failover *Failover) authorize(resource authn.Resource) (authn.Authenticator, error) {
+	authenticator, err := failover.keychain.Resolve(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", resource.RegistryStr(), err)
+	}
+	if _, err := authenticator.Authorization(); err != nil {
+		return nil, fmt.Errorf("failed to authenticate to %s: %w", resource.RegistryStr(), err)
+	}
+	return authenticator, nil
+}
+
+// isRegistryUnavailable reports whether err is a registry failing with a server error or throttling, or not being reachable at all.
+func isRegistryUnavailable(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode >= http.StatusInternalServerError || terr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// rewriteRepository returns ref with its registry and repository replaced by repository, parsed with opts.
+func rewriteRepository(ref name.Reference, repository string, opts ...name.Option) (name.Reference, error) {
+	if _, ok := ref.(name.Digest); ok {
+		return name.NewDigest(repository+"@"+ref.Identifier(), opts...)
+	}
+	return name.NewTag(repository+":"+ref.Identifier(), opts...)
+}
@@ -0,0 +1,84 @@
+package ecr
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailover(t *testing.T) {
+	t.Parallel()
+	keychain := newTestKeychain("us-west-2/false", &fakeClient{err: errors.New("service unavailable")})
+	replica := newFakeClient("AWS", "replica", 12*time.Hour)
+	entry := &keychainEntry{authenticator: newAuthenticator(replica, keychain.opts)}
+	entry.once.Do(entry.publish)
+	keychain.cache.Load().Store("us-east-2/false", entry)
+
+	failover := NewFailover(keychain, map[string][]string{"us-west-2": {"us-east-2"}})
+	tests := map[string]string{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1":                    "123456789012.dkr.ecr.us-east-2.amazonaws.com/app:v1",
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/team/app@sha256:" + digest: "123456789012.dkr.ecr.us-east-2.amazonaws.com/team/app@sha256:" + digest,
+		"123456789012.dkr.ecr.us-east-2.amazonaws.com/app:v1":                    "123456789012.dkr.ecr.us-east-2.amazonaws.com/app:v1",
+	}
+	for ref, expected := range tests {
+		ref, expected := ref, expected
+		t.Run(ref, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := name.ParseReference(ref)
+			require.NoError(t, err)
+			resolved, authenticator, err := failover.Resolve(parsed)
+			require.NoError(t, err)
+			assert.Equal(t, expected, resolved.String())
+			authConfig, err := authenticator.Authorization()
+			require.NoError(t, err)
+			assert.Equal(t, "replica", authConfig.Password)
+		})
+	}
+
+	_, _, err := NewFailover(keychain, nil).Resolve(name.MustParseReference("123456789012.dkr.ecr.us-west-2.amazonaws.com/app"))
+	assert.ErrorContains(t, err, "service unavailable")
+}
+
+func TestFailoverDo(t *testing.T) {
+	t.Parallel()
+	// Both regions have a valid token, the outage is only visible on the registry calls of fn.
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "primary", 12*time.Hour))
+	entry := &keychainEntry{authenticator: newAuthenticator(newFakeClient("AWS", "replica", 12*time.Hour), keychain.opts)}
+	entry.once.Do(entry.publish)
+	keychain.cache.Load().Store("us-east-2/false", entry)
+	failover := NewFailover(keychain, map[string][]string{"us-west-2": {"us-east-2"}}, name.Insecure)
+
+	var pulled []string
+	err := failover.Do(name.MustParseReference("123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1"), func(ref name.Reference, authenticator authn.Authenticator) error {
+		pulled = append(pulled, ref.String())
+		if ref.Context().RegistryStr() == "123456789012.dkr.ecr.us-west-2.amazonaws.com" {
+			return &transport.Error{StatusCode: http.StatusServiceUnavailable}
+		}
+		assert.Equal(t, "http", ref.Context().Scheme())
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "replica", authConfig.Password)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1", "123456789012.dkr.ecr.us-east-2.amazonaws.com/app:v1"}, pulled)
+
+	// Errors other than the registry being unavailable are returned without trying the replicas.
+	pulled = nil
+	err = failover.Do(name.MustParseReference("123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1"), func(ref name.Reference, authenticator authn.Authenticator) error {
+		pulled = append(pulled, ref.String())
+		return &transport.Error{StatusCode: http.StatusNotFound}
+	})
+	assert.Error(t, err)
+	assert.Len(t, pulled, 1)
+}
+
+// digest is an arbitrary sha256 digest.
+const digest = "0000000000000000000000000000000000000000000000000000000000000000"
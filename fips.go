@@ -0,0 +1,76 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// isUnreachableError reports whether err means the request could not be sent, such as a DNS or connection failure.
+func isUnreachableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var sendErr *smithyhttp.RequestSendError
+	return errors.As(err, &sendErr)
+}
+
+// disableFIPS makes a request use the standard instead of the FIPS endpoint.
+func disableFIPS(o *ecr.Options) {
+	o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateDisabled
+}
+
+// standardEndpoint returns the option making a request for the FIPS registry reg use the standard endpoint.
+// Registries whose partition sets the ECR API endpoint switch to the standard endpoint of the partition, as disabling FIPS has no effect on an explicit BaseEndpoint.
+func (keychain *ecrKeychain) standardEndpoint(reg *Registry) func(*ecr.Options) {
+	standard := *reg
+	standard.FIPS = false
+	endpoint, ok := partitionEndpoint(&standard)
+	if !ok || keychain.opts.baseEndpoint != "" {
+		return disableFIPS
+	}
+	return func(o *ecr.Options) {
+		disableFIPS(o)
+		o.BaseEndpoint = aws.String(endpoint)
+	}
+}
+
+// fipsReprobeInterval is how long a FIPS registry uses the standard endpoint after falling back before the FIPS endpoint is tried again.
+const fipsReprobeInterval = 5 * time.Minute
+
+// fipsFallbackClient wraps the ECR client of a FIPS registry, retrying against the standard endpoint when the FIPS endpoint is unreachable.
+// The fallback only sticks for fipsReprobeInterval so a transient failure does not downgrade the registry for good.
+type fipsFallbackClient struct {
+	client ecrClient
+	logger *slog.Logger
+	now    func() time.Time
+	// standard makes a request use the standard endpoint.
+	standard func(*ecr.Options)
+	// standardUntil is the UnixNano time until which the standard endpoint is used without trying the FIPS endpoint first.
+	standardUntil atomic.Int64
+}
+
+func (c *fipsFallbackClient) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	if c.now().UnixNano() < c.standardUntil.Load() {
+		c.logger.Warn("using the standard ECR endpoint instead of FIPS", "endpoint", "standard")
+		return c.client.GetAuthorizationToken(ctx, params, append(optFns, c.standard)...)
+	}
+	out, err := c.client.GetAuthorizationToken(ctx, params, optFns...)
+	if !isUnreachableError(err) {
+		return out, err
+	}
+	c.logger.Warn("FIPS endpoint is unreachable, retrying against the standard endpoint", "error", err)
+	out, fallbackErr := c.client.GetAuthorizationToken(ctx, params, append(optFns, c.standard)...)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fallbackErr)
+	}
+	c.standardUntil.Store(c.now().Add(fipsReprobeInterval).UnixNano())
+	c.logger.Warn("using the standard ECR endpoint instead of FIPS", "endpoint", "standard", "reprobe_in", fipsReprobeInterval)
+	return out, nil
+}
@@ -0,0 +1,95 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFIPSFallbackClient(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	now := time.Now()
+	var fipsCalls int
+	fallback := &fipsFallbackClient{
+		client: funcGetAuthorizationToken(func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+			var o ecr.Options
+			for _, fn := range optFns {
+				fn(&o)
+			}
+			if o.EndpointOptions.UseFIPSEndpoint != aws.FIPSEndpointStateDisabled {
+				fipsCalls++
+				return nil, &smithyhttp.RequestSendError{Err: errors.New("dial tcp: no such host")}
+			}
+			return client.GetAuthorizationToken(ctx, params, optFns...)
+		}),
+		logger:   newOptions(nil).logger,
+		now:      func() time.Time { return now },
+		standard: disableFIPS,
+	}
+	for i := 0; i < 2; i++ {
+		_, err := fallback.GetAuthorizationToken(context.Background(), &ecr.GetAuthorizationTokenInput{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, fipsCalls)
+	assert.EqualValues(t, 2, client.calls.Load())
+
+	// The FIPS endpoint is tried again once the fallback has expired.
+	now = now.Add(fipsReprobeInterval)
+	_, err := fallback.GetAuthorizationToken(context.Background(), &ecr.GetAuthorizationTokenInput{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fipsCalls)
+	assert.EqualValues(t, 3, client.calls.Load())
+}
+
+func TestIsUnreachableError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"send error": {err: &smithyhttp.RequestSendError{Err: errors.New("connection refused")}, expected: true},
+		"canceled":   {err: &smithyhttp.RequestSendError{Err: context.Canceled}},
+		"api error":  {err: errors.New("AccessDeniedException")},
+		"nil":        {},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, isUnreachableError(tt.err))
+		})
+	}
+}
+
+func TestKeychainFIPSFallback(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{}, WithFIPSFallback()).(*ecrKeychain)
+	authenticator := keychain.newAuthenticator(aws.Config{}, Parse("123456789012.dkr.ecr-fips.us-west-2.amazonaws.com"), "us-west-2/true", keychain.opts.logger)
+	_, ok := authenticator.(*ecrAuthenticator).client.(*fipsFallbackClient)
+	assert.True(t, ok)
+
+	authenticator = keychain.newAuthenticator(aws.Config{}, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"), "us-west-2/false", keychain.opts.logger)
+	_, ok = authenticator.(*ecrAuthenticator).client.(*fipsFallbackClient)
+	assert.False(t, ok)
+}
+
+func TestKeychainFIPSFallbackPartitionEndpoint(t *testing.T) {
+	t.Cleanup(resetPartitions())
+	RegisterPartition(Partition{Name: "aws-test", DNSSuffix: "example.test", RegionPrefix: "xx-test-"})
+	keychain := NewKeychain(aws.Config{}, WithFIPSFallback()).(*ecrKeychain)
+	authenticator := keychain.newAuthenticator(aws.Config{}, Parse("123456789012.dkr.ecr-fips.xx-test-1.example.test"), "xx-test-1/true", keychain.opts.logger)
+	fallback, ok := authenticator.(*ecrAuthenticator).client.(*fipsFallbackClient)
+	require.True(t, ok)
+
+	o := ecr.Options{BaseEndpoint: aws.String("https://ecr-fips.xx-test-1.example.test")}
+	fallback.standard(&o)
+	assert.Equal(t, "https://api.ecr.xx-test-1.example.test", aws.ToString(o.BaseEndpoint))
+	assert.Equal(t, aws.FIPSEndpointStateDisabled, o.EndpointOptions.UseFIPSEndpoint)
+}
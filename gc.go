@@ -0,0 +1,62 @@
+package ecr
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheGCInterval is how often Resolve garbage collects the keychain cache unless WithCacheGC is used.
+var DefaultCacheGCInterval = 15 * time.Minute
+
+// DefaultCacheIdleTimeout is how long a cached authenticator may go unresolved before it is dropped unless WithCacheGC is used.
+var DefaultCacheIdleTimeout = 12 * time.Hour
+
+// expirer is implemented by the authenticators of this package to drop their cached token once it has expired.
+type expirer interface {
+	dropExpired(now time.Time)
+}
+
+func (authenticator *ecrAuthenticator) dropExpired(now time.Time) {
+	if cached := authenticator.cache.Load(); cached != nil && !now.Before(cached.ExpiresAt) {
+		authenticator.cache.CompareAndSwap(cached, nil)
+	}
+}
+
+// dropExpired is only called by gc on published entries, the fallback and the authenticator it wraps are never modified once constructed.
+func (fallback *anonymousFallback) dropExpired(now time.Time) {
+	if authenticator, ok := fallback.authenticator.(expirer); ok {
+		authenticator.dropExpired(now)
+	}
+}
+
+// maybeGC garbage collects the cache if the GC interval has elapsed since the last collection, at most one caller collects at a time.
+func (keychain *ecrKeychain) maybeGC() {
+	if keychain.opts.gcInterval <= 0 {
+		return
+	}
+	now := keychain.opts.now()
+	last := keychain.lastGC.Load()
+	if now.Sub(time.Unix(0, last)) < keychain.opts.gcInterval || !keychain.lastGC.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	keychain.gc(keychain.cache.Load(), now)
+}
+
+// gc drops the expired tokens of the authenticators in cache and the entries not resolved within the idle timeout.
+func (keychain *ecrKeychain) gc(cache *sync.Map, now time.Time) {
+	cache.Range(func(key, value any) bool {
+		entry := value.(*keychainEntry)
+		if keychain.opts.idleTimeout > 0 && now.Sub(time.Unix(0, entry.lastUsed.Load())) >= keychain.opts.idleTimeout {
+			keychain.opts.logger.Debug("dropping idle ecr keychain cache entry", "key", key)
+			cache.CompareAndDelete(key, entry)
+			return true
+		}
+		if !entry.ready.Load() {
+			return true
+		}
+		if authenticator, ok := entry.authenticator.(expirer); ok {
+			authenticator.dropExpired(now)
+		}
+		return true
+	})
+}
@@ -0,0 +1,51 @@
+package ecr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychainGC(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client, WithClock(func() time.Time { return now }), WithCacheGC(time.Minute, 24*time.Hour))
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	_, err = authenticator.Authorization()
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	keychain.maybeGC()
+	assert.NotNil(t, authenticator.(*ecrAuthenticator).cache.Load())
+
+	now = client.expiresAt
+	keychain.maybeGC()
+	assert.Nil(t, authenticator.(*ecrAuthenticator).cache.Load())
+	_, ok := keychain.cache.Load().Load("us-west-2/false")
+	assert.True(t, ok)
+
+	now = now.Add(24 * time.Hour)
+	keychain.maybeGC()
+	_, ok = keychain.cache.Load().Load("us-west-2/false")
+	assert.False(t, ok)
+}
+
+func TestKeychainGCConcurrentResolve(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{}).(*ecrKeychain)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			keychain.gc(keychain.cache.Load(), time.Now())
+		}
+	}()
+	_, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	<-done
+}
@@ -0,0 +1,45 @@
+package ecr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// WriteGitHubActionsOutputs sets the username, password and registry outputs of the current GitHub Actions step to the credentials keychain resolves for registry,
+// so workflows can feed docker/login-action or buildx without the AWS CLI. The password is masked in the log by writing ::add-mask:: to stdout first,
+// the outputs are appended to the file named by the GITHUB_OUTPUT environment variable.
+func WriteGitHubActionsOutputs(keychain authn.Keychain, registry string, stdout io.Writer) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return errors.New("GITHUB_OUTPUT is not set, not running in a GitHub Actions step")
+	}
+	username, password, _, err := HostCredentials(keychain, registry)
+	if err != nil {
+		return err
+	}
+	outputs := [][2]string{{"username", username}, {"password", password}, {"registry", registry}}
+	for _, output := range outputs {
+		if strings.ContainsAny(output[1], "\r\n") {
+			return fmt.Errorf("the %s output contains a newline", output[0])
+		}
+	}
+	if _, err := fmt.Fprintf(stdout, "::add-mask::%s\n", password); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		if _, err := fmt.Fprintf(f, "%s=%s\n", output[0], output[1]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
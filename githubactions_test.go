@@ -0,0 +1,28 @@
+package ecr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGitHubActionsOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	require.NoError(t, os.WriteFile(path, []byte("previous=step\n"), 0o600))
+	t.Setenv("GITHUB_OUTPUT", path)
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour))
+	var stdout strings.Builder
+	require.NoError(t, WriteGitHubActionsOutputs(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com", &stdout))
+	assert.Equal(t, "::add-mask::password\n", stdout.String())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "previous=step\nusername=AWS\npassword=password\nregistry=123456789012.dkr.ecr.us-west-2.amazonaws.com\n", string(b))
+
+	t.Setenv("GITHUB_OUTPUT", "")
+	assert.Error(t, WriteGitHubActionsOutputs(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com", &stdout))
+}
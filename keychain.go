@@ -2,79 +2,401 @@ package ecr
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecrpublic"
 	"github.com/google/go-containerregistry/pkg/authn"
 )
 
-// ecrKeychain implements the authn.Keychain interface.
+// Keychain is an authn.Keychain for ECR registries with additional methods to manage the credentials it caches.
+type Keychain interface {
+	authn.Keychain
+
+	// Warm resolves each registry and fetches its token ahead of time so later pulls are served from the cache.
+	Warm(ctx context.Context, registries ...string) error
+
+	// UpdateConfig atomically replaces the AWS configuration and discards every authenticator derived from the previous one.
+	UpdateConfig(cfg aws.Config)
+
+	// SetOffline toggles offline mode, where tokens are only served from the cache and AWS is never called.
+	// This allows tokens prefetched with Warm to be used during network-partitioned stages, failing with ErrOffline once they expire.
+	SetOffline(offline bool)
+
+	// Lease returns the credentials of registry along with when the keychain will refresh them, zero if they never expire,
+	// so orchestration code such as a secret syncer knows exactly when to fetch them again.
+	Lease(ctx context.Context, registry string) (authn.AuthConfig, time.Time, error)
+
+	// ResolveContext is like Resolve but ctx governs loading the AWS configuration, Resolve uses the context of WithBaseContext.
+	ResolveContext(ctx context.Context, resource authn.Resource) (authn.Authenticator, error)
+
+	// ResolveAll resolves many resources concurrently, optionally fetching their tokens too, returning the authenticators keyed by registry.
+	// This avoids resolving and fetching sequentially in fan-out pulls, the errors of the registries that failed are joined.
+	ResolveAll(ctx context.Context, resources []authn.Resource, authorize bool) (map[string]authn.Authenticator, error)
+
+	// Notify returns a channel receiving a RefreshEvent each time a new token is minted, so copies of the credentials
+	// such as a generated config file can be updated immediately. Events are dropped if the channel is not drained.
+	Notify() <-chan RefreshEvent
+}
+
+// ecrKeychain implements the Keychain interface.
 type ecrKeychain struct {
-	cfg         aws.Config
-	cache       map[string]authn.Authenticator
-	cacheMu     sync.RWMutex
-	earlyExpiry time.Duration
+	loadConfig func(ctx context.Context) (aws.Config, error)
+	cfg        atomic.Pointer[loadedConfig]
+	cfgMu      sync.Mutex
+	cache      atomic.Pointer[sync.Map]
+	opts       options
+	notifier   notifier
+	lastGC     atomic.Int64
+	profile    atomic.Pointer[string]
+	notECR     negativeCache
+	// partitionRoles caches the roles assumed with each configuration of WithConfigForPartition, keyed by partition.
+	partitionRoles map[string]*sync.Map
 }
 
-// Resolve returns an authn.Authenticator instance for the given registry or authn.Anonymous if not an ECR URL.
-func (keychain *ecrKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
-	reg := Parse(resource.RegistryStr())
-	if reg == nil {
-		return authn.Anonymous, nil
+// loadedConfig is the memoized result of loading the AWS configuration.
+type loadedConfig struct {
+	cfg      aws.Config
+	err      error
+	loadedAt time.Time
+	// roles caches the aws.CredentialsProvider of the roles assumed per account (and region with STSEndpointRegional).
+	roles sync.Map
+}
+
+// DefaultConfigRetryBackoff is how long an error loading the AWS configuration is memoized unless WithConfigRetryBackoff is used.
+var DefaultConfigRetryBackoff = 30 * time.Second
+
+// sharedPool caches the authenticators of every keychain using WithSharedPool.
+var sharedPool sync.Map
+
+// keychainEntry is a cached authenticator that is constructed at most once per key, so concurrent Resolves never serialize on a global lock.
+type keychainEntry struct {
+	once          sync.Once
+	authenticator authn.Authenticator
+	err           error
+	lastUsed      atomic.Int64
+	// ready is set once authenticator is constructed, gc must not read it before as once.Do may still be writing it.
+	ready atomic.Bool
+}
+
+// publish marks the authenticator of entry as constructed.
+func (entry *keychainEntry) publish() {
+	entry.ready.Store(true)
+}
+
+// config returns the AWS configuration, loading it on first use and memoizing the result.
+// An error is memoized too, until the retry backoff of WithConfigRetryBackoff has elapsed.
+func (keychain *ecrKeychain) config(ctx context.Context) (aws.Config, error) {
+	if loaded := keychain.cfg.Load(); loaded != nil && !keychain.retryConfig(loaded) {
+		return loaded.cfg, loaded.err
+	}
+	keychain.cfgMu.Lock()
+	defer keychain.cfgMu.Unlock()
+	if loaded := keychain.cfg.Load(); loaded != nil && !keychain.retryConfig(loaded) {
+		return loaded.cfg, loaded.err
+	}
+	cfg, err := keychain.loadConfig(ctx)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up, don't memoize an error another caller may not hit.
+		return cfg, err
+	} else if err != nil {
+		keychain.cfg.Store(&loadedConfig{cfg: cfg, err: err, loadedAt: keychain.opts.now()})
+		return cfg, err
+	}
+	loaded := keychain.newLoadedConfig(cfg)
+	keychain.cfg.Store(loaded)
+	return loaded.cfg, nil
+}
+
+// retryConfig reports whether loaded is an error that should no longer be memoized.
+func (keychain *ecrKeychain) retryConfig(loaded *loadedConfig) bool {
+	return loaded.err != nil && keychain.opts.configRetryBackoff > 0 && keychain.opts.now().Sub(loaded.loadedAt) >= keychain.opts.configRetryBackoff
+}
+
+// newLoadedConfig applies the options of the keychain that derive credentials from a loaded AWS configuration.
+func (keychain *ecrKeychain) newLoadedConfig(cfg aws.Config) *loadedConfig {
+	if keychain.opts.webIdentity != nil {
+		cfg.Credentials = keychain.opts.webIdentity.provider(cfg, keychain.addAPIOptions)
+	}
+	return &loadedConfig{cfg: cfg}
+}
+
+// configFor returns the AWS configuration used for reg, which is the one from WithConfigForPartition if its partition has one.
+func (keychain *ecrKeychain) configFor(ctx context.Context, reg *Registry) (aws.Config, error) {
+	if cfg, ok := keychain.opts.partitionConfigs[reg.Partition()]; ok {
+		return cfg, nil
+	}
+	return keychain.config(ctx)
+}
+
+// UpdateConfig replaces the AWS configuration and invalidates the cached authenticators.
+func (keychain *ecrKeychain) UpdateConfig(cfg aws.Config) {
+	keychain.cfgMu.Lock()
+	defer keychain.cfgMu.Unlock()
+	// The config must be replaced before the cache so a Resolve observing the new cache also observes the new config.
+	keychain.cfg.Store(keychain.newLoadedConfig(cfg))
+	// The shared pool is keyed by identity so authenticators of the previous credentials are never reused.
+	if !keychain.opts.sharedPool {
+		keychain.cache.Store(new(sync.Map))
+	}
+}
+
+// credentials returns the aws.CredentialsProvider used to fetch tokens for reg.
+func (keychain *ecrKeychain) credentials(cfg aws.Config, reg *Registry) aws.CredentialsProvider {
+	if keychain.opts.credentialsFor != nil {
+		if provider := keychain.opts.credentialsFor(reg); provider != nil {
+			return provider
+		}
 	}
+	if roles, ok := keychain.opts.assumeRoles[reg.AccountID]; ok {
+		return keychain.assumedRole(cfg, reg, roles)
+	}
+	return cfg.Credentials
+}
+
+// SetOffline toggles offline mode for the keychain and every authenticator it has created.
+func (keychain *ecrKeychain) SetOffline(offline bool) {
+	keychain.opts.offline.Store(offline)
+}
+
+// cacheKey returns the key the authenticator for reg is cached under.
+func (keychain *ecrKeychain) cacheKey(ctx context.Context, reg *Registry) (string, error) {
 	key := reg.Region + "/" + strconv.FormatBool(reg.FIPS)
-	keychain.cacheMu.RLock()
-	if auth, ok := keychain.cache[key]; ok {
-		keychain.cacheMu.RUnlock()
-		return auth, nil
+	if reg.DNSSuffix == ecrPublicDomain {
+		key = ecrPublicDomain
+	}
+	// Credentials, early expiry and strict ProxyEndpoints may differ per account so the account must be part of the key.
+	if keychain.opts.credentialsFor != nil || len(keychain.opts.assumeRoles) > 0 || len(keychain.opts.earlyExpiryFor) > 0 || keychain.opts.strictProxyEndpoint {
+		key += "/" + reg.AccountID
+	}
+	if !keychain.opts.identityCacheKey {
+		return key, nil
+	}
+	cfg, err := keychain.configFor(ctx, reg)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	provider := keychain.credentials(cfg, reg)
+	if provider == nil {
+		return key, nil
+	}
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("(aws.CredentialsProvider).Retrieve failed: %w", err)
 	}
-	keychain.cacheMu.RUnlock()
-	client := ecr.NewFromConfig(keychain.cfg, func(opts *ecr.Options) {
+	return key + "/" + creds.AccessKeyID, nil
+}
+
+// newClient returns a new ECR client for reg.
+func (keychain *ecrKeychain) newClient(cfg aws.Config, reg *Registry, optFns ...func(*ecr.Options)) *ecr.Client {
+	configure := func(opts *ecr.Options) {
 		opts.Region = reg.Region
 		if reg.FIPS {
 			opts.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
 		}
+		opts.Credentials = keychain.credentials(cfg, reg)
+		if keychain.opts.httpClient != nil {
+			opts.HTTPClient = keychain.opts.httpClient
+		}
+		if keychain.opts.baseEndpoint != "" {
+			opts.BaseEndpoint = aws.String(keychain.opts.baseEndpoint)
+		} else if endpoint, ok := partitionEndpoint(reg); ok {
+			opts.BaseEndpoint = aws.String(endpoint)
+		}
+		opts.APIOptions = append(opts.APIOptions, addUserAgent)
+		opts.APIOptions = append(opts.APIOptions, keychain.opts.apiOptions...)
+	}
+	return ecr.NewFromConfig(cfg, append([]func(*ecr.Options){configure}, optFns...)...)
+}
+
+// Resolve returns an authn.Authenticator instance for the given registry or authn.Anonymous if not an ECR URL.
+func (keychain *ecrKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	return keychain.ResolveContext(keychain.opts.baseContext, resource)
+}
+
+// ResolveContext is like Resolve but ctx governs loading the AWS configuration if needed.
+func (keychain *ecrKeychain) ResolveContext(ctx context.Context, resource authn.Resource) (authn.Authenticator, error) {
+	if keychain.notECR.contains(resource.RegistryStr()) {
+		return authn.Anonymous, nil
+	}
+	keychain.checkProfile()
+	logger := keychain.opts.logger
+	// Adding attributes to the logger allocates, so only do it when they are logged to keep cache hits cheap.
+	debug := logger.Enabled(ctx, slog.LevelDebug)
+	if debug {
+		logger = logger.With("registry", resource.RegistryStr())
+	}
+	reg := keychain.opts.parse(ResolveAlias(keychain.opts.aliases, resource.RegistryStr()))
+	if reg == nil {
+		logger.Debug("registry is not ECR, using anonymous")
+		keychain.notECR.add(resource.RegistryStr())
+		return authn.Anonymous, nil
+	}
+	if reg.DNSSuffix == ecrPublicDomain && keychain.opts.withoutECRPublic {
+		logger.Debug("ECR Public is excluded, using anonymous")
+		return authn.Anonymous, nil
+	}
+	if debug {
+		logger = logger.With("region", reg.Region, "fips", reg.FIPS)
+	}
+	key, err := keychain.cacheKey(ctx, reg)
+	if err != nil {
+		logger.Debug("failed to compute cache key", "error", err)
+		if reg.DNSSuffix == ecrPublicDomain && keychain.opts.anonymousPublicFallback {
+			return authn.Anonymous, nil
+		}
+		return nil, err
+	}
+	if debug {
+		logger = logger.With("key", key)
+	}
+	keychain.maybeGC()
+	cache := keychain.cache.Load()
+	value, loaded := cache.Load(key)
+	if !loaded {
+		value, loaded = cache.LoadOrStore(key, &keychainEntry{})
+	}
+	entry := value.(*keychainEntry)
+	entry.lastUsed.Store(keychain.opts.now().UnixNano())
+	if loaded {
+		logger.Debug("ecr keychain cache hit")
+	}
+	entry.once.Do(func() {
+		// The authenticator logs at other levels too, so it always gets the attributes.
+		logger := keychain.opts.logger.With("registry", resource.RegistryStr(), "region", reg.Region, "fips", reg.FIPS, "key", key)
+		logger.Debug("ecr keychain cache miss, creating authenticator")
+		cfg, err := keychain.configFor(ctx, reg)
+		if err != nil {
+			logger.Debug("failed to load AWS config", "error", err)
+			entry.err = fmt.Errorf("failed to load AWS config: %w", err)
+			// Don't cache the failure, the next Resolve should try again.
+			cache.CompareAndDelete(key, entry)
+			return
+		}
+		entry.authenticator = keychain.newAuthenticator(cfg, reg, key, logger)
+		entry.publish()
 	})
-	authenticator := NewAuthenticatorWithEarlyExpiry(client, keychain.earlyExpiry)
-	keychain.cacheMu.Lock()
-	defer keychain.cacheMu.Unlock()
-	if auth, ok := keychain.cache[key]; ok {
-		return auth, nil
+	return entry.authenticator, entry.err
+}
+
+// newAuthenticator returns a new authenticator for reg.
+func (keychain *ecrKeychain) newAuthenticator(cfg aws.Config, reg *Registry, key string, logger *slog.Logger) authn.Authenticator {
+	opts := keychain.opts
+	opts.logger = logger
+	opts.registry = reg
+	opts.sharedCacheKey = keychain.opts.sharedCacheKey + "/" + key
+	opts.earlyExpiry = keychain.opts.registryEarlyExpiry(reg)
+	opts.onRefresh = func(expiresAt time.Time) {
+		keychain.notifier.notify(RefreshEvent{Registry: reg.String(), ExpiresAt: expiresAt})
+	}
+	// Sign with a skewSigner so a rejection due to clock skew can be retried with a corrected signing time.
+	signer := &skewSigner{signer: v4.NewSigner()}
+	var client ecrClient
+	if reg.DNSSuffix == ecrPublicDomain {
+		client = publicTokenFunc(keychain.newPublicClient(cfg, reg, func(o *ecrpublic.Options) {
+			o.HTTPSignerV4 = signer
+		}))
+	} else {
+		client = keychain.newClient(cfg, reg, func(o *ecr.Options) {
+			o.HTTPSignerV4 = signer
+		})
+	}
+	client = &skewClient{client: client, signer: signer}
+	if reg.FIPS && keychain.opts.fipsFallback {
+		client = &fipsFallbackClient{client: client, logger: logger, now: keychain.opts.now, standard: keychain.standardEndpoint(reg)}
 	}
-	keychain.cache[key] = authenticator
-	return authenticator, nil
+	var authenticator authn.Authenticator = newAuthenticator(client, opts)
+	if reg.DNSSuffix == ecrPublicDomain && keychain.opts.anonymousPublicFallback {
+		authenticator = &anonymousFallback{
+			authenticator: authenticator,
+			credentials:   keychain.credentials(cfg, reg),
+			logger:        logger,
+			baseContext:   opts.baseContext,
+		}
+	}
+	if len(keychain.opts.authenticatorMiddlewares) == 0 {
+		return authenticator
+	}
+	wrapped := authenticator
+	for idx := len(keychain.opts.authenticatorMiddlewares) - 1; idx >= 0; idx-- {
+		wrapped = keychain.opts.authenticatorMiddlewares[idx](wrapped)
+	}
+	return &middlewareAuthenticator{Authenticator: wrapped, inner: authenticator}
 }
 
 // NewKeychainWithEarlyExpiry returns a new Keychain instance with a custom earlyExpiry value.
-func NewKeychainWithEarlyExpiry(cfg aws.Config, earlyExpiry time.Duration) authn.Keychain {
-	return &ecrKeychain{
-		cfg:         cfg,
-		cache:       make(map[string]authn.Authenticator),
-		earlyExpiry: earlyExpiry,
-	}
+func NewKeychainWithEarlyExpiry(cfg aws.Config, earlyExpiry time.Duration) Keychain {
+	return NewKeychain(cfg, WithEarlyExpiry(earlyExpiry))
 }
 
 // NewKeychain returns a new Keychain instance that uses the provided AWS configuration.
-func NewKeychain(cfg aws.Config) authn.Keychain {
-	return NewKeychainWithEarlyExpiry(cfg, DefaultEarlyExpiry)
+func NewKeychain(cfg aws.Config, opts ...Option) Keychain {
+	return newKeychain(func(context.Context) (aws.Config, error) {
+		return cfg, nil
+	}, opts)
+}
+
+// newKeychain returns a new ecrKeychain that calls loadConfig once on the first ECR registry resolved.
+func newKeychain(loadConfig func(ctx context.Context) (aws.Config, error), opts []Option) *ecrKeychain {
+	keychain := &ecrKeychain{
+		loadConfig: loadConfig,
+		opts:       newOptions(opts),
+	}
+	keychain.lastGC.Store(keychain.opts.now().UnixNano())
+	if len(keychain.opts.partitionConfigs) > 0 {
+		keychain.partitionRoles = make(map[string]*sync.Map, len(keychain.opts.partitionConfigs))
+		for partition := range keychain.opts.partitionConfigs {
+			keychain.partitionRoles[partition] = new(sync.Map)
+		}
+	}
+	if keychain.opts.sharedPool {
+		keychain.cache.Store(&sharedPool)
+	} else {
+		keychain.cache.Store(new(sync.Map))
+	}
+	return keychain
 }
 
 // DefaultKeychain uses the default AWS credentials chain.
-func DefaultKeychain(ctx context.Context) (authn.Keychain, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+func DefaultKeychain(ctx context.Context, opts ...Option) (Keychain, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, newOptions(opts).loadOptions...)
 	if err != nil {
 		return nil, err
 	}
-	return NewKeychain(cfg), nil
+	keychain := LazyDefaultKeychain(opts...).(*ecrKeychain)
+	keychain.cfg.Store(keychain.newLoadedConfig(cfg))
+	return keychain, nil
 }
 
+// LazyDefaultKeychain is like DefaultKeychain but defers loading the AWS configuration until the first ECR registry is resolved.
+// The loaded configuration is memoized for the lifetime of the keychain, an error loading it is memoized until DefaultConfigRetryBackoff
+// (or the backoff of WithConfigRetryBackoff) has elapsed. This makes it safe for libraries to register unconditionally, unlike MustDefaultKeychain.
+func LazyDefaultKeychain(opts ...Option) Keychain {
+	keychain := newKeychain(nil, opts)
+	keychain.loadConfig = func(ctx context.Context) (aws.Config, error) {
+		return config.LoadDefaultConfig(ctx, keychain.opts.loadOptions...)
+	}
+	return keychain
+}
+
+// AmbientKeychain is a drop-in replacement for authn.DefaultKeychain that falls back to a LazyDefaultKeychain for ECR registries.
+// The AWS configuration is only loaded once an ECR registry is resolved which makes it safe to use from package initialization.
+var AmbientKeychain authn.Keychain = authn.NewMultiKeychain(authn.DefaultKeychain, lazyDefaultKeychain)
+
+// lazyDefaultKeychain is the package-level LazyDefaultKeychain shared by AmbientKeychain and Resolve, so they load the AWS configuration and cache tokens once.
+var lazyDefaultKeychain = LazyDefaultKeychain()
+
 // MustDefaultKeychain is like DefaultKeychain but panics on error.
-func MustDefaultKeychain(ctx context.Context) authn.Keychain {
-	keychain, err := DefaultKeychain(ctx)
+func MustDefaultKeychain(ctx context.Context, opts ...Option) Keychain {
+	keychain, err := DefaultKeychain(ctx, opts...)
 	if err != nil {
 		panic(err)
 	}
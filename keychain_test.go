@@ -0,0 +1,514 @@
+package ecr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKeychain returns an ecrKeychain with the authenticator for key seeded from client.
+func newTestKeychain(key string, client ecrClient, opts ...Option) *ecrKeychain {
+	keychain := NewKeychain(aws.Config{}, opts...).(*ecrKeychain)
+	entry := &keychainEntry{authenticator: newAuthenticator(client, keychain.opts)}
+	entry.once.Do(entry.publish)
+	keychain.cache.Load().Store(key, entry)
+	return keychain
+}
+
+func TestKeychainResolve(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+
+	authenticator, err = keychain.Resolve(registryResource("index.docker.io"))
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+}
+
+func TestKeychainWarm(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	err := keychain.Warm(context.Background(),
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		"210987654321.dkr.ecr.us-west-2.amazonaws.com",
+		"index.docker.io",
+	)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, client.calls.Load())
+
+	failing := &fakeClient{err: errors.New("boom")}
+	keychain = newTestKeychain("us-west-2/false", failing)
+	err = keychain.Warm(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	assert.ErrorContains(t, err, "failed to warm 123456789012.dkr.ecr.us-west-2.amazonaws.com")
+}
+
+func TestLoginAll(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	failing := &keychainEntry{authenticator: newAuthenticator(&fakeClient{err: errors.New("boom")}, keychain.opts)}
+	failing.once.Do(failing.publish)
+	keychain.cache.Load().Store("us-east-1/false", failing)
+	results := LoginAll(context.Background(), keychain, []string{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		"index.docker.io",
+	}, LoginOptions{Concurrency: 2})
+	require.Len(t, results, 3)
+	assert.Equal(t, LoginResult{
+		Registry:   "123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		AuthConfig: authn.AuthConfig{Username: "AWS", Password: "password"},
+		ExpiresAt:  client.expiresAt.Add(-DefaultEarlyExpiry),
+	}, results[0])
+	assert.ErrorContains(t, results[1].Err, "failed to authenticate to 123456789012.dkr.ecr.us-east-1.amazonaws.com")
+	assert.Equal(t, LoginResult{Registry: "index.docker.io"}, results[2])
+}
+
+func TestKeychainResolveAll(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	failing := &keychainEntry{authenticator: newAuthenticator(&fakeClient{err: errors.New("boom")}, keychain.opts)}
+	failing.once.Do(failing.publish)
+	keychain.cache.Load().Store("us-east-1/false", failing)
+	resources := []authn.Resource{
+		registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+		registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+		registryResource("123456789012.dkr.ecr.us-east-1.amazonaws.com"),
+		registryResource("index.docker.io"),
+	}
+
+	authenticators, err := keychain.ResolveAll(context.Background(), resources, false)
+	require.NoError(t, err)
+	assert.Len(t, authenticators, 3)
+	assert.EqualValues(t, 0, client.calls.Load())
+
+	authenticators, err = keychain.ResolveAll(context.Background(), resources, true)
+	assert.ErrorContains(t, err, "failed to resolve 123456789012.dkr.ecr.us-east-1.amazonaws.com")
+	assert.Len(t, authenticators, 2)
+	assert.Equal(t, authn.Anonymous, authenticators["index.docker.io"])
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestKeychainLogger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour), WithLogger(logger))
+	_, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"msg":"ecr keychain cache hit"`)
+	assert.Contains(t, buf.String(), `"key":"us-west-2/false"`)
+}
+
+func TestKeychainLazyConfig(t *testing.T) {
+	t.Parallel()
+	var loads atomic.Int32
+	keychain := newKeychain(func(context.Context) (aws.Config, error) {
+		loads.Add(1)
+		return aws.Config{}, errors.New("no credentials")
+	}, nil)
+
+	_, err := keychain.Resolve(registryResource("index.docker.io"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, loads.Load())
+
+	for i := 0; i < 2; i++ {
+		_, err = keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+		assert.ErrorContains(t, err, "no credentials")
+	}
+	assert.EqualValues(t, 1, loads.Load())
+}
+
+func TestKeychainUpdateConfig(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	before, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+
+	keychain.UpdateConfig(aws.Config{Region: "us-east-1"})
+	after, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.NotSame(t, before, after)
+
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", cfg.Region)
+}
+
+func TestKeychainIdentityCacheKey(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+	}, WithIdentityCacheKey()).(*ecrKeychain)
+	key, err := keychain.cacheKey(context.Background(), Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2/false/AKIAEXAMPLE", key)
+}
+
+func TestKeychainCredentialsFor(t *testing.T) {
+	t.Parallel()
+	static := credentials.NewStaticCredentialsProvider("AKIAACCOUNTB", "secret", "")
+	keychain := NewKeychain(aws.Config{}, WithIdentityCacheKey(), WithCredentialsFor(func(reg *Registry) aws.CredentialsProvider {
+		if reg.AccountID == "210987654321" {
+			return static
+		}
+		return nil
+	})).(*ecrKeychain)
+	key, err := keychain.cacheKey(context.Background(), Parse("210987654321.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2/false/210987654321/AKIAACCOUNTB", key)
+	key, err = keychain.cacheKey(context.Background(), Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2/false/123456789012", key)
+}
+
+func TestKeychainHTTPClient(t *testing.T) {
+	t.Parallel()
+	httpClient := &http.Client{Timeout: time.Second}
+	keychain := NewKeychain(aws.Config{}, WithHTTPClient(httpClient)).(*ecrKeychain)
+	client := keychain.newClient(aws.Config{}, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	assert.Same(t, httpClient, client.Options().HTTPClient)
+}
+
+func TestKeychainAPIOptions(t *testing.T) {
+	t.Parallel()
+	var called bool
+	keychain := NewKeychain(aws.Config{}, WithAPIOptions(func(*middleware.Stack) error {
+		called = true
+		return nil
+	})).(*ecrKeychain)
+	client := keychain.newClient(aws.Config{}, Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	stack := middleware.NewStack("test", smithyhttp.NewStackRequest)
+	for _, fn := range client.Options().APIOptions {
+		require.NoError(t, fn(stack))
+	}
+	assert.True(t, called)
+}
+
+func TestKeychainLocalStack(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-east-1/false", client, WithBaseEndpoint("http://localhost:4566"), WithParser(func(ref string) *Registry {
+		if ref == "localhost:4566" {
+			return &Registry{AccountID: "000000000000", Region: "us-east-1", DNSSuffix: "localhost.localstack.cloud"}
+		}
+		return Parse(ref)
+	}))
+	authenticator, err := keychain.Resolve(registryResource("localhost:4566"))
+	require.NoError(t, err)
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+
+	ecrClient := keychain.newClient(aws.Config{}, Parse("123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+	assert.Equal(t, "http://localhost:4566", aws.ToString(ecrClient.Options().BaseEndpoint))
+}
+
+func TestKeychainOffline(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client, WithOffline())
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	_, err = authenticator.Authorization()
+	assert.ErrorIs(t, err, ErrOffline)
+
+	keychain.SetOffline(false)
+	require.NoError(t, keychain.Warm(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	keychain.SetOffline(true)
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestKeychainConcurrentResolve(t *testing.T) {
+	t.Parallel()
+	var loads atomic.Int32
+	keychain := newKeychain(func(context.Context) (aws.Config, error) {
+		loads.Add(1)
+		return aws.Config{}, nil
+	}, nil)
+	authenticators := make([]authn.Authenticator, 64)
+	var wg sync.WaitGroup
+	for i := range authenticators {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+			assert.NoError(t, err)
+			authenticators[i] = authenticator
+		}(i)
+	}
+	wg.Wait()
+	for _, authenticator := range authenticators {
+		assert.Same(t, authenticators[0], authenticator)
+	}
+	assert.EqualValues(t, 1, loads.Load())
+}
+
+func TestKeychainAnonymousPublicFallback(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{}, WithAnonymousPublicFallback())
+	authenticator, err := keychain.Resolve(registryResource("public.ecr.aws"))
+	require.NoError(t, err)
+	authConfig, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, &authn.AuthConfig{}, authConfig)
+}
+
+func TestAnonymousFallbackExpiry(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	fallback := &anonymousFallback{
+		authenticator: newAuthenticator(client, newOptions(nil)),
+		credentials:   credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+		logger:        slog.New(discardHandler{}),
+		baseContext:   context.Background(),
+	}
+	authConfig, expiresAt, err := authorization(context.Background(), fallback)
+	require.NoError(t, err)
+	assert.Equal(t, "password", authConfig.Password)
+	assert.Equal(t, client.expiresAt.Add(-DefaultEarlyExpiry), expiresAt)
+
+	fallback.credentials = nil
+	authConfig, expiresAt, err = authorization(context.Background(), fallback)
+	require.NoError(t, err)
+	assert.Equal(t, &authn.AuthConfig{}, authConfig)
+	assert.True(t, expiresAt.IsZero())
+}
+
+func TestKeychainWithoutECRPublic(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{}, WithoutECRPublic())
+	authenticator, err := keychain.Resolve(registryResource("public.ecr.aws"))
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+}
+
+func TestKeychainECRPublicRegion(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		opts   []Option
+		region string
+	}{
+		"default":  {region: DefaultECRPublicRegion},
+		"override": {opts: []Option{WithECRPublicRegion("us-west-2")}, region: "us-west-2"},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := aws.Config{Region: "eu-west-1"}
+			keychain := NewKeychain(cfg, tt.opts...).(*ecrKeychain)
+			client := keychain.newPublicClient(cfg, Parse(ecrPublicDomain))
+			assert.Equal(t, tt.region, client.Options().Region)
+		})
+	}
+}
+
+func TestAmbientKeychain(t *testing.T) {
+	t.Parallel()
+	authenticator, err := AmbientKeychain.Resolve(registryResource("example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+}
+
+func TestKeychainWebIdentity(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{Region: "us-west-2"}, WithWebIdentity("arn:aws:iam::123456789012:role/pull", "/var/run/secrets/token", "ci")).(*ecrKeychain)
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+	assert.True(t, aws.IsCredentialsProvider(cfg.Credentials, (*stscreds.WebIdentityRoleProvider)(nil)))
+}
+
+func TestKeychainMFATokenProvider(t *testing.T) {
+	t.Parallel()
+	var loadOptions config.LoadOptions
+	for _, fn := range newOptions([]Option{WithMFATokenProvider(func() (string, error) { return "123456", nil })}).loadOptions {
+		require.NoError(t, fn(&loadOptions))
+	}
+	var assumeRoleOptions stscreds.AssumeRoleOptions
+	loadOptions.AssumeRoleCredentialOptions(&assumeRoleOptions)
+	token, err := assumeRoleOptions.TokenProvider()
+	require.NoError(t, err)
+	assert.Equal(t, "123456", token)
+}
+
+func TestKeychainIMDS(t *testing.T) {
+	t.Parallel()
+	var loadOptions config.LoadOptions
+	for _, fn := range newOptions([]Option{WithoutIMDS(), WithIMDSTimeout(time.Second)}).loadOptions {
+		require.NoError(t, fn(&loadOptions))
+	}
+	assert.Equal(t, imds.ClientDisabled, loadOptions.EC2IMDSClientEnableState)
+	var ec2RoleOptions ec2rolecreds.Options
+	loadOptions.EC2RoleCredentialOptions(&ec2RoleOptions)
+	assert.IsType(t, &imds.Client{}, ec2RoleOptions.Client)
+}
+
+func TestKeychainProfile(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(configFile, []byte("[profile prod]\nregion = eu-west-1\n"), 0o600))
+	keychain, err := DefaultKeychain(context.Background(),
+		WithProfile("prod"),
+		WithSharedConfigFiles([]string{configFile}, []string{filepath.Join(dir, "credentials")}),
+		WithoutIMDS(),
+	)
+	require.NoError(t, err)
+	cfg, err := keychain.(*ecrKeychain).config(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", cfg.Region)
+}
+
+func TestKeychainConfigForPartition(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{Region: "us-west-2"}, WithConfigForPartition(map[string]aws.Config{
+		"aws-cn": {Region: "cn-north-1"},
+	})).(*ecrKeychain)
+	cfg, err := keychain.configFor(context.Background(), Parse("123456789012.dkr.ecr.cn-northwest-1.amazonaws.com.cn"))
+	require.NoError(t, err)
+	assert.Equal(t, "cn-north-1", cfg.Region)
+	cfg, err = keychain.configFor(context.Background(), Parse("123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", cfg.Region)
+}
+
+func TestKeychainAliases(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client, WithAliases(map[string]string{"prod": "123456789012.dkr.ecr.us-west-2.amazonaws.com"}))
+	require.NoError(t, keychain.Warm(context.Background(), "prod"))
+	assert.EqualValues(t, 1, client.calls.Load())
+}
+
+func TestKeychainSharedPool(t *testing.T) {
+	t.Parallel()
+	cfg := aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIASHAREDPOOL", "secret", "")}
+	first := NewKeychain(cfg, WithSharedPool())
+	second := NewKeychain(cfg, WithSharedPool())
+	other := NewKeychain(aws.Config{Credentials: credentials.NewStaticCredentialsProvider("AKIAOTHERPOOL", "secret", "")}, WithSharedPool())
+	a, err := first.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	b, err := second.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	c, err := other.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	assert.Same(t, a, b)
+	assert.NotSame(t, a, c)
+}
+
+func TestKeychainConfigCanceled(t *testing.T) {
+	t.Parallel()
+	keychain := newKeychain(func(ctx context.Context) (aws.Config, error) {
+		return aws.Config{Region: "us-west-2"}, ctx.Err()
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := keychain.config(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", cfg.Region)
+}
+
+func TestKeychainEarlyExpiryFor(t *testing.T) {
+	t.Parallel()
+	opts := newOptions([]Option{WithEarlyExpiryFor(map[string]time.Duration{
+		"public.ecr.aws":                          time.Minute,
+		"*.dkr.ecr.*.amazonaws.com":               2 * time.Minute,
+		"*.dkr.ecr.eu-west-1.amazonaws.com":       3 * time.Minute,
+		"123456789012":                            4 * time.Minute,
+		"123456789012.dkr.ecr.*.amazonaws.com.cn": 5 * time.Minute,
+	})})
+	tests := map[string]time.Duration{
+		"public.ecr.aws": time.Minute,
+		"111111111111.dkr.ecr.us-west-2.amazonaws.com":      2 * time.Minute,
+		"111111111111.dkr.ecr.eu-west-1.amazonaws.com":      3 * time.Minute,
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com":      3 * time.Minute,
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":  5 * time.Minute,
+		"123456789012.dkr.ecr-fips.us-east-1.amazonaws.com": 4 * time.Minute,
+		"111111111111.dkr.ecr.cn-north-1.amazonaws.com.cn":  DefaultEarlyExpiry,
+	}
+	for registry, earlyExpiry := range tests {
+		registry, earlyExpiry := registry, earlyExpiry
+		t.Run(registry, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, earlyExpiry, opts.registryEarlyExpiry(Parse(registry)))
+		})
+	}
+}
+
+func TestKeychainConfigRetryBackoff(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	var loads atomic.Int32
+	keychain := newKeychain(func(context.Context) (aws.Config, error) {
+		if loads.Add(1) == 1 {
+			return aws.Config{}, errors.New("no credentials")
+		}
+		return aws.Config{Region: "us-west-2"}, nil
+	}, []Option{WithClock(func() time.Time { return now }), WithConfigRetryBackoff(time.Minute)})
+
+	_, err := keychain.config(context.Background())
+	assert.ErrorContains(t, err, "no credentials")
+	_, err = keychain.config(context.Background())
+	assert.ErrorContains(t, err, "no credentials")
+	assert.EqualValues(t, 1, loads.Load())
+
+	now = now.Add(time.Minute)
+	cfg, err := keychain.config(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", cfg.Region)
+	assert.EqualValues(t, 2, loads.Load())
+}
+
+func BenchmarkResolve(b *testing.B) {
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour))
+	benchmarks := map[string]authn.Resource{
+		"ecr":     registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+		"not ecr": registryResource("index.docker.io"),
+	}
+	for name, resource := range benchmarks {
+		resource := resource
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := keychain.Resolve(resource); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package ecr
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// KubernetesSecretExpiresAtAnnotation is the annotation of the Secrets of NewKubernetesSecret recording when their credentials expire.
+const KubernetesSecretExpiresAtAnnotation = "docker-credential-ecr/expires-at"
+
+// kubernetesSecret is the subset of a Kubernetes core/v1 Secret written by NewKubernetesSecret.
+type kubernetesSecret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   kubernetesObject  `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string][]byte `json:"data"`
+}
+
+// kubernetesObject is the subset of the metadata of a Kubernetes object written by NewKubernetesSecret.
+type kubernetesObject struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NewKubernetesSecret returns the JSON manifest of a kubernetes.io/dockerconfigjson Secret named name in namespace with the DockerConfig of registries,
+// annotated with KubernetesSecretExpiresAtAnnotation unless the expiry is unknown. It can be applied with kubectl apply -f, such as from a CronJob refreshing it before expiry.
+func NewKubernetesSecret(keychain authn.Keychain, name, namespace string, registries ...string) ([]byte, error) {
+	dockerConfig, expiresAt, err := NewDockerConfig(keychain, registries...)
+	if err != nil {
+		return nil, err
+	}
+	dockerConfigJSON, err := json.Marshal(dockerConfig)
+	if err != nil {
+		return nil, err
+	}
+	secret := kubernetesSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   kubernetesObject{Name: name, Namespace: namespace},
+		Type:       "kubernetes.io/dockerconfigjson",
+		Data:       map[string][]byte{".dockerconfigjson": dockerConfigJSON},
+	}
+	if !expiresAt.IsZero() {
+		secret.Metadata.Annotations = map[string]string{KubernetesSecretExpiresAtAnnotation: expiresAt.UTC().Format(time.RFC3339)}
+	}
+	return json.MarshalIndent(secret, "", "  ")
+}
@@ -0,0 +1,30 @@
+package ecr
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKubernetesSecret(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	manifest, err := NewKubernetesSecret(keychain, "ecr", "ci", "123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	require.NoError(t, err)
+	dockerConfigJSON := `{"auths":{"123456789012.dkr.ecr.us-west-2.amazonaws.com":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("AWS:password")) + `"}}}`
+	assert.JSONEq(t, `{
+		"apiVersion": "v1",
+		"kind": "Secret",
+		"metadata": {
+			"name": "ecr",
+			"namespace": "ci",
+			"annotations": {"docker-credential-ecr/expires-at": "`+client.expiresAt.Add(-DefaultEarlyExpiry).UTC().Format(time.RFC3339)+`"}
+		},
+		"type": "kubernetes.io/dockerconfigjson",
+		"data": {".dockerconfigjson": "`+base64.StdEncoding.EncodeToString([]byte(dockerConfigJSON))+`"}
+	}`, string(manifest))
+}
@@ -0,0 +1,51 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// CallMetrics describes an AWS API call made by the keychain, see WithCallMetrics.
+type CallMetrics struct {
+	// Service and Operation identify the call, such as ECR and GetAuthorizationToken.
+	Service   string
+	Operation string
+	// Latency includes every attempt and the backoff between them.
+	Latency  time.Duration
+	Attempts int
+	// ErrorCode is the AWS error code of a failed call such as ThrottlingException, empty on success or when there is no code.
+	ErrorCode string
+	Err       error
+}
+
+// callMetricsMiddleware returns middleware reporting the CallMetrics of every call to record.
+func callMetricsMiddleware(record func(CallMetrics)) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CallMetrics", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			start := time.Now()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			metrics := CallMetrics{
+				Service:   awsmiddleware.GetServiceID(ctx),
+				Operation: awsmiddleware.GetOperationName(ctx),
+				Latency:   time.Since(start),
+				Attempts:  1,
+				Err:       err,
+			}
+			if results, ok := retry.GetAttemptResults(metadata); ok && len(results.Results) > 0 {
+				metrics.Attempts = len(results.Results)
+			}
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) {
+				metrics.ErrorCode = apiErr.ErrorCode()
+			}
+			record(metrics)
+			return out, metadata, err
+		}), middleware.After)
+	}
+}
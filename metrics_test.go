@@ -0,0 +1,41 @@
+package ecr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychainCallMetrics(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"InvalidParameterException","message":"boom"}`))
+	}))
+	defer server.Close()
+
+	var metrics []CallMetrics
+	keychain := NewKeychain(aws.Config{
+		Region:      "us-west-2",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+	}, WithBaseEndpoint(server.URL), WithCallMetrics(func(m CallMetrics) {
+		metrics = append(metrics, m)
+	}))
+	authenticator, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+	require.NoError(t, err)
+	_, err = authenticator.Authorization()
+	require.Error(t, err)
+
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "ECR", metrics[0].Service)
+	assert.Equal(t, "GetAuthorizationToken", metrics[0].Operation)
+	assert.Equal(t, 1, metrics[0].Attempts)
+	assert.Equal(t, "InvalidParameterException", metrics[0].ErrorCode)
+	assert.Error(t, metrics[0].Err)
+}
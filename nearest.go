@@ -0,0 +1,88 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/sync/errgroup"
+)
+
+// NearestRegistry returns the registry of accountID with the lowest latency out of the regions it is replicated to, and its authenticator.
+// If localRegion is one of regions it is selected without probing, typically the region of the aws.Config or the instance.
+// Otherwise the /v2/ endpoint of each registry is requested concurrently using client (http.DefaultClient if nil).
+func NearestRegistry(ctx context.Context, keychain authn.Keychain, client *http.Client, accountID, localRegion string, regions ...string) (*Registry, authn.Authenticator, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	reg, err := nearestRegistry(ctx, accountID, localRegion, regions, func(ctx context.Context, reg *Registry) (time.Duration, error) {
+		return probeRegistry(ctx, client, reg)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	authenticator, err := resolveContext(ctx, keychain, registryResource(reg.String()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return reg, authenticator, nil
+}
+
+// nearestRegistry implements NearestRegistry using probe to measure the latency of each registry.
+func nearestRegistry(ctx context.Context, accountID, localRegion string, regions []string, probe func(ctx context.Context, reg *Registry) (time.Duration, error)) (*Registry, error) {
+	if len(regions) == 0 {
+		return nil, errors.New("no regions provided")
+	}
+	registries := make([]*Registry, len(regions))
+	for idx, region := range regions {
+		registries[idx] = &Registry{AccountID: accountID, Region: region, DNSSuffix: dnsSuffixForRegion(region)}
+	}
+	if idx := slices.Index(regions, localRegion); idx >= 0 {
+		return registries[idx], nil
+	}
+	latencies := make([]time.Duration, len(registries))
+	errs := make([]error, len(registries))
+	var g errgroup.Group
+	g.SetLimit(DefaultWarmConcurrency)
+	for idx, reg := range registries {
+		idx, reg := idx, reg
+		g.Go(func() error {
+			latency, err := probe(ctx, reg)
+			if err != nil {
+				errs[idx] = fmt.Errorf("failed to probe %s: %w", reg, err)
+			}
+			latencies[idx] = latency
+			return nil
+		})
+	}
+	_ = g.Wait()
+	nearest := -1
+	for idx := range registries {
+		if errs[idx] == nil && (nearest < 0 || latencies[idx] < latencies[nearest]) {
+			nearest = idx
+		}
+	}
+	if nearest < 0 {
+		return nil, errors.Join(errs...)
+	}
+	return registries[nearest], nil
+}
+
+// probeRegistry returns how long the /v2/ endpoint of reg takes to respond, any status code is considered a response.
+func probeRegistry(ctx context.Context, client *http.Client, reg *Registry) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+reg.String()+"/v2/", nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return time.Since(start), nil
+}
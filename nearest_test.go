@@ -0,0 +1,38 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNearestRegistry(t *testing.T) {
+	t.Parallel()
+	latencies := map[string]time.Duration{
+		"us-west-2":  80 * time.Millisecond,
+		"eu-west-1":  20 * time.Millisecond,
+		"cn-north-1": 0,
+	}
+	probe := func(ctx context.Context, reg *Registry) (time.Duration, error) {
+		if reg.Region == "cn-north-1" {
+			return 0, errors.New("unreachable")
+		}
+		return latencies[reg.Region], nil
+	}
+	regions := []string{"us-west-2", "eu-west-1", "cn-north-1"}
+
+	reg, err := nearestRegistry(context.Background(), "123456789012", "", regions, probe)
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012.dkr.ecr.eu-west-1.amazonaws.com", reg.String())
+
+	reg, err = nearestRegistry(context.Background(), "123456789012", "cn-north-1", regions, probe)
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", reg.String())
+
+	_, err = nearestRegistry(context.Background(), "123456789012", "", []string{"cn-north-1"}, probe)
+	assert.ErrorContains(t, err, "unreachable")
+}
@@ -0,0 +1,41 @@
+package ecr
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// negativeCacheSize bounds the number of hostnames remembered by a negativeCache.
+const negativeCacheSize = 1024
+
+// negativeCache is a bounded set of registry hostnames known not to be ECR, so Resolve can skip parsing them.
+// Once full it is emptied rather than evicting individual entries, the hostnames still in use are added back on their next Resolve.
+// Each hostname records the partitionsGeneration it was added in, so RegisterPartition and RegisterDNSSuffix invalidate it.
+type negativeCache struct {
+	hosts sync.Map
+	size  atomic.Int64
+}
+
+// contains reports whether host is known not to be ECR.
+func (c *negativeCache) contains(host string) bool {
+	generation, ok := c.hosts.Load(host)
+	return ok && generation.(uint64) == partitionsGeneration.Load()
+}
+
+// add records that host is not ECR.
+func (c *negativeCache) add(host string) {
+	if c.contains(host) {
+		return
+	}
+	if c.size.Load() >= negativeCacheSize {
+		c.hosts.Range(func(key, _ any) bool {
+			if _, loaded := c.hosts.LoadAndDelete(key); loaded {
+				c.size.Add(-1)
+			}
+			return true
+		})
+	}
+	if _, loaded := c.hosts.Swap(host, partitionsGeneration.Load()); !loaded {
+		c.size.Add(1)
+	}
+}
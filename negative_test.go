@@ -0,0 +1,59 @@
+package ecr
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegativeCache(t *testing.T) {
+	t.Parallel()
+	var cache negativeCache
+	for i := 0; i < negativeCacheSize; i++ {
+		cache.add("registry" + strconv.Itoa(i) + ".example.com")
+	}
+	cache.add("registry0.example.com")
+	assert.EqualValues(t, negativeCacheSize, cache.size.Load())
+	assert.True(t, cache.contains("registry0.example.com"))
+
+	cache.add("docker.io")
+	assert.EqualValues(t, 1, cache.size.Load())
+	assert.True(t, cache.contains("docker.io"))
+	assert.False(t, cache.contains("registry0.example.com"))
+}
+
+func TestKeychainNegativeCache(t *testing.T) {
+	t.Parallel()
+	var parsed int
+	keychain := NewKeychain(aws.Config{}, WithParser(func(ref string) *Registry {
+		parsed++
+		return Parse(ref)
+	})).(*ecrKeychain)
+	for i := 0; i < 3; i++ {
+		authenticator, err := keychain.Resolve(registryResource("ghcr.io"))
+		require.NoError(t, err)
+		assert.Equal(t, authn.Anonymous, authenticator)
+	}
+	assert.Equal(t, 1, parsed)
+	assert.True(t, keychain.notECR.contains("ghcr.io"))
+}
+
+func TestKeychainNegativeCacheRegisterDNSSuffix(t *testing.T) {
+	t.Cleanup(resetPartitions())
+	host := "123456789012.dkr.ecr.us-west-2.ecr.negative.example"
+	keychain := NewKeychain(aws.Config{}).(*ecrKeychain)
+	authenticator, err := keychain.Resolve(registryResource(host))
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+	assert.True(t, keychain.notECR.contains(host))
+
+	RegisterDNSSuffix("ecr.negative.example", "aws", DNSSuffixOptions{})
+	assert.False(t, keychain.notECR.contains(host))
+	authenticator, err = keychain.Resolve(registryResource(host))
+	require.NoError(t, err)
+	assert.NotEqual(t, authn.Anonymous, authenticator)
+}
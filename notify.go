@@ -0,0 +1,48 @@
+package ecr
+
+import (
+	"sync"
+	"time"
+)
+
+// notifyBuffer is the capacity of the channels returned by Keychain.Notify.
+const notifyBuffer = 16
+
+// RefreshEvent is sent to the channels returned by Keychain.Notify each time a new token is minted.
+type RefreshEvent struct {
+	// Registry is the registry the authenticator was resolved for, the token is also used for every registry sharing its cache key.
+	Registry  string
+	ExpiresAt time.Time
+}
+
+// notifier fans out RefreshEvents to every channel returned by subscribe.
+type notifier struct {
+	mu          sync.Mutex
+	subscribers []chan RefreshEvent
+}
+
+// subscribe returns a new channel receiving every following RefreshEvent.
+func (n *notifier) subscribe() <-chan RefreshEvent {
+	ch := make(chan RefreshEvent, notifyBuffer)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subscribers = append(n.subscribers, ch)
+	return ch
+}
+
+// notify sends event to every subscriber without blocking, dropping it for subscribers whose channel is full.
+func (n *notifier) notify(event RefreshEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ch := range n.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Notify returns a channel receiving a RefreshEvent each time the keychain mints a new token.
+func (keychain *ecrKeychain) Notify() <-chan RefreshEvent {
+	return keychain.notifier.subscribe()
+}
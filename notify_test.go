@@ -0,0 +1,31 @@
+package ecr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychainNotify(t *testing.T) {
+	t.Parallel()
+	keychain := NewKeychain(aws.Config{Region: "us-west-2"}).(*ecrKeychain)
+	events := keychain.Notify()
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	authenticator := keychain.newAuthenticator(aws.Config{}, reg, "us-west-2/false", keychain.opts.logger).(*ecrAuthenticator)
+	authenticator.client = client
+	for i := 0; i < 2; i++ {
+		_, err := authenticator.Authorization()
+		require.NoError(t, err)
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, RefreshEvent{Registry: reg.String(), ExpiresAt: client.expiresAt.Add(-DefaultEarlyExpiry)}, <-events)
+
+	authenticator.invalidate()
+	_, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
@@ -0,0 +1,468 @@
+package ecr
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/smithy-go/middleware"
+	"golang.org/x/time/rate"
+)
+
+// Option configures the Keychain and Authenticator instances returned by this package.
+type Option func(*options)
+
+// options is the configuration shared by ecrKeychain and the ecrAuthenticator instances it creates.
+type options struct {
+	earlyExpiry              time.Duration
+	logger                   *slog.Logger
+	identityCacheKey         bool
+	credentialsFor           func(reg *Registry) aws.CredentialsProvider
+	httpClient               aws.HTTPClient
+	apiOptions               []func(*middleware.Stack) error
+	baseEndpoint             string
+	parse                    func(ref string) *Registry
+	jitter                   time.Duration
+	maxTokenAge              time.Duration
+	offline                  *atomic.Bool
+	now                      func() time.Time
+	anonymousPublicFallback  bool
+	withoutECRPublic         bool
+	ecrPublicRegion          string
+	fipsFallback             bool
+	webIdentity              *webIdentity
+	loadOptions              []func(*config.LoadOptions) error
+	assumeRoles              map[string][]AssumeRole
+	defaultExternalID        string
+	stsEndpoint              STSEndpoint
+	partitionConfigs         map[string]aws.Config
+	aliases                  map[string]string
+	limiter                  *rate.Limiter
+	rateLimitFailFast        bool
+	sharedPool               bool
+	sealer                   *sealer
+	onRefresh                func(expiresAt time.Time)
+	baseContext              context.Context
+	earlyExpiryFor           map[string]time.Duration
+	refreshFraction          float64
+	gcInterval               time.Duration
+	idleTimeout              time.Duration
+	authenticatorMiddlewares []AuthenticatorMiddleware
+	sharedCache              Cache
+	sharedCacheKey           string
+	cacheOnly                bool
+	configRetryBackoff       time.Duration
+	profileReload            bool
+	strictProxyEndpoint      bool
+	registry                 *Registry
+	defaultTokenLifetime     time.Duration
+}
+
+// newOptions applies opts on top of the package defaults.
+func newOptions(opts []Option) options {
+	o := options{
+		earlyExpiry:          DefaultEarlyExpiry,
+		logger:               slog.New(discardHandler{}),
+		parse:                Parse,
+		baseContext:          context.Background(),
+		offline:              new(atomic.Bool),
+		now:                  time.Now,
+		ecrPublicRegion:      DefaultECRPublicRegion,
+		gcInterval:           DefaultCacheGCInterval,
+		idleTimeout:          DefaultCacheIdleTimeout,
+		configRetryBackoff:   DefaultConfigRetryBackoff,
+		defaultTokenLifetime: DefaultTokenLifetime,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithEarlyExpiry sets how long before the ECR reported expiry a cached token is refreshed.
+func WithEarlyExpiry(earlyExpiry time.Duration) Option {
+	return func(o *options) {
+		o.earlyExpiry = earlyExpiry
+	}
+}
+
+// WithLogger emits debug level records to logger covering the registries resolved, cache hits/misses and AWS errors.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger == nil {
+			logger = slog.New(discardHandler{})
+		}
+		o.logger = logger
+	}
+}
+
+// WithIdentityCacheKey includes the access key ID of the resolved AWS credentials in the keychain cache key.
+// This ensures a process that switches roles mid-lifetime does not reuse authenticators minted for the previous identity,
+// at the cost of retrieving the credentials on every Resolve and a new token fetch whenever temporary credentials rotate.
+func WithIdentityCacheKey() Option {
+	return func(o *options) {
+		o.identityCacheKey = true
+	}
+}
+
+// WithCredentialsFor overrides the credentials used for a registry with the provider returned by credentialsFor.
+// Returning nil falls back to the credentials of the aws.Config, providers should typically be wrapped in aws.NewCredentialsCache.
+// When set, authenticators are cached per account in addition to region and FIPS.
+func WithCredentialsFor(credentialsFor func(reg *Registry) aws.CredentialsProvider) Option {
+	return func(o *options) {
+		o.credentialsFor = credentialsFor
+	}
+}
+
+// WithHTTPClient uses client for the AWS API calls made by the clients the keychain constructs instead of the aws.Config's HTTPClient.
+// This allows a corporate proxy, custom CA bundle or tuned timeouts to be used for ECR only, *http.Client satisfies aws.HTTPClient.
+func WithHTTPClient(client aws.HTTPClient) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithAPIOptions appends middleware to the APIOptions of the clients the keychain constructs.
+func WithAPIOptions(apiOptions ...func(*middleware.Stack) error) Option {
+	return func(o *options) {
+		o.apiOptions = append(o.apiOptions, apiOptions...)
+	}
+}
+
+// WithBaseEndpoint overrides the ECR API endpoint of the clients the keychain constructs, such as an emulator like LocalStack.
+func WithBaseEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.baseEndpoint = endpoint
+	}
+}
+
+// WithParser replaces Parse when the keychain resolves a registry, returning nil treats the registry as not ECR.
+// This allows hosts such as a LocalStack emulated registry to resolve through the ECR auth flow, typically by wrapping Parse.
+func WithParser(parse func(ref string) *Registry) Option {
+	return func(o *options) {
+		o.parse = parse
+	}
+}
+
+// WithJitter refreshes cached tokens up to a random duration within window earlier than the early expiry.
+// This spreads refreshes out when many replicas start at the same time with the same early expiry.
+func WithJitter(window time.Duration) Option {
+	return func(o *options) {
+		o.jitter = window
+	}
+}
+
+// WithMaxTokenAge caps how long a token is cached after being fetched regardless of the expiry reported by ECR.
+func WithMaxTokenAge(maxTokenAge time.Duration) Option {
+	return func(o *options) {
+		o.maxTokenAge = maxTokenAge
+	}
+}
+
+// WithOffline starts the keychain in offline mode, see Keychain.SetOffline.
+func WithOffline() Option {
+	return func(o *options) {
+		o.offline.Store(true)
+	}
+}
+
+// WithClock replaces time.Now when checking whether cached tokens have expired, allowing deterministic tests of expiry behavior.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithAnonymousPublicFallback authenticates to public.ecr.aws anonymously when no AWS credentials can be resolved.
+// ECR Public allows unauthenticated pulls with lower rate limits, so tools keep working on machines without AWS credentials.
+func WithAnonymousPublicFallback() Option {
+	return func(o *options) {
+		o.anonymousPublicFallback = true
+	}
+}
+
+// WithoutECRPublic makes the keychain return authn.Anonymous for public.ecr.aws, leaving it to another keychain or an anonymous proxy.
+func WithoutECRPublic() Option {
+	return func(o *options) {
+		o.withoutECRPublic = true
+	}
+}
+
+// WithECRPublicRegion overrides the region of the ECR Public client, which defaults to DefaultECRPublicRegion regardless of the aws.Config region.
+func WithECRPublicRegion(region string) Option {
+	return func(o *options) {
+		o.ecrPublicRegion = region
+	}
+}
+
+// WithFIPSFallback retries token fetches for FIPS registries against the standard ECR endpoint when the FIPS endpoint is unreachable.
+// After a fallback the standard endpoint is used for a few minutes before the FIPS endpoint is tried again, which is logged with the "endpoint" attribute at warn level.
+func WithFIPSFallback() Option {
+	return func(o *options) {
+		o.fipsFallback = true
+	}
+}
+
+// WithWebIdentity authenticates with sts:AssumeRoleWithWebIdentity using the token in tokenFile instead of the credentials of the aws.Config.
+// This is the flow used by IRSA and EKS Pod Identity webhooks, for pods where AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE are not set or non-standard.
+// An empty sessionName is replaced by a name generated by the SDK.
+func WithWebIdentity(roleARN, tokenFile, sessionName string) Option {
+	return func(o *options) {
+		o.webIdentity = &webIdentity{roleARN: roleARN, tokenFile: tokenFile, sessionName: sessionName}
+	}
+}
+
+// WithMFATokenProvider prompts for an MFA token with tokenProvider when DefaultKeychain or LazyDefaultKeychain assume a role requiring MFA.
+// Without it a profile with mfa_serial set fails with an error instead of prompting, stscreds.StdinTokenProvider prompts on the terminal.
+func WithMFATokenProvider(tokenProvider func() (string, error)) Option {
+	return func(o *options) {
+		o.loadOptions = append(o.loadOptions, config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = tokenProvider
+		}))
+	}
+}
+
+// WithAssumeRole assumes roles in order to fetch tokens for the registries of accountID, authenticators are then cached per account.
+// The first role is assumed using the credentials of the aws.Config and each following role using the credentials of the previous,
+// such as a bastion role followed by the role of the account. WithCredentialsFor takes precedence if it returns a provider.
+func WithAssumeRole(accountID string, roles ...AssumeRole) Option {
+	return func(o *options) {
+		if o.assumeRoles == nil {
+			o.assumeRoles = make(map[string][]AssumeRole)
+		}
+		o.assumeRoles[accountID] = roles
+	}
+}
+
+// WithDefaultExternalID sets the ExternalId passed to sts:AssumeRole for the roles of WithAssumeRole without an ExternalID.
+func WithDefaultExternalID(externalID string) Option {
+	return func(o *options) {
+		o.defaultExternalID = externalID
+	}
+}
+
+// WithSTSEndpoint selects the STS endpoint the roles of WithAssumeRole are assumed with instead of the SDK default.
+func WithSTSEndpoint(endpoint STSEndpoint) Option {
+	return func(o *options) {
+		o.stsEndpoint = endpoint
+	}
+}
+
+// WithConfigForPartition uses the configuration in configs keyed by partition name (see Registry.Partition) for registries in that partition.
+// This allows pulling from aws-cn or ISO registries, which require entirely separate credentials, alongside the default partition.
+func WithConfigForPartition(configs map[string]aws.Config) Option {
+	return func(o *options) {
+		o.partitionConfigs = configs
+	}
+}
+
+// WithAliases lets the keychain resolve friendly names such as prod in place of the registry hostname they map to, see ResolveAlias.
+func WithAliases(aliases map[string]string) Option {
+	return func(o *options) {
+		o.aliases = aliases
+	}
+}
+
+// WithRateLimit limits the GetAuthorizationToken calls of the keychain to limit per second with bursts of burst calls.
+// ECR API quotas are shared by every client of an account, so a misbehaving fleet can otherwise starve the others.
+// Calls over the limit wait for a token unless failFast is set, in which case they fail with ErrRateLimited.
+func WithRateLimit(limit rate.Limit, burst int, failFast bool) Option {
+	return func(o *options) {
+		o.limiter = rate.NewLimiter(limit, burst)
+		o.rateLimitFailFast = failFast
+	}
+}
+
+// WithSharedPool shares authenticators with every other keychain of the process using WithSharedPool,
+// caching them by the access key ID of the credentials (see WithIdentityCacheKey) in addition to region and FIPS.
+// This avoids multiplying token fetches when libraries construct several keychains from the same aws.Config,
+// the options of the keychain creating an authenticator (such as WithEarlyExpiry) apply to every keychain sharing it.
+func WithSharedPool() Option {
+	return func(o *options) {
+		o.sharedPool = true
+		o.identityCacheKey = true
+	}
+}
+
+// WithCallMetrics calls record after every ECR and STS API call the keychain makes with its latency, attempts and error code.
+// This makes the overhead of authentication measurable, such as by exporting record to a metrics library.
+func WithCallMetrics(record func(CallMetrics)) Option {
+	return func(o *options) {
+		o.apiOptions = append(o.apiOptions, callMetricsMiddleware(record))
+	}
+}
+
+// WithoutIMDS disables the EC2 instance metadata service when DefaultKeychain or LazyDefaultKeychain load the AWS config.
+// On hosts outside of EC2 this avoids the latency of probing IMDS for credentials that will never be found.
+func WithoutIMDS() Option {
+	return func(o *options) {
+		o.loadOptions = append(o.loadOptions, config.WithEC2IMDSClientEnableState(imds.ClientDisabled))
+	}
+}
+
+// WithIMDSTimeout bounds each request to the EC2 instance metadata service by timeout and disables its retries
+// when DefaultKeychain or LazyDefaultKeychain fall back to EC2 instance role credentials.
+func WithIMDSTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.loadOptions = append(o.loadOptions, config.WithEC2RoleCredentialOptions(func(o *ec2rolecreds.Options) {
+			o.Client = imds.New(imds.Options{
+				HTTPClient: awshttp.NewBuildableClient().WithTimeout(timeout),
+				Retryer:    aws.NopRetryer{},
+			})
+		}))
+	}
+}
+
+// WithEncryptedCache holds cached passwords encrypted in memory with an ephemeral per-process key, decrypting them only inside Authorization.
+// Decoded token buffers are cleared once used, this is best-effort as the strings of the returned authn.AuthConfig cannot be cleared.
+func WithEncryptedCache() Option {
+	return func(o *options) {
+		o.sealer = newSealer()
+	}
+}
+
+// WithBaseContext governs the AWS calls of Resolve and Authorization with ctx, such as to cancel them on shutdown.
+// ResolveContext and AuthorizationContext use the context they are given instead. Defaults to context.Background().
+func WithBaseContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.baseContext = ctx
+	}
+}
+
+// WithEarlyExpiryFor overrides the early expiry of WithEarlyExpiry for registries matching a glob of earlyExpiry, matched using path.Match
+// against the registry hostname such as "*.dkr.ecr.*.amazonaws.com" or "public.ecr.aws", or against the account ID such as "1234*".
+// When several globs match a registry the longest one is used.
+func WithEarlyExpiryFor(earlyExpiry map[string]time.Duration) Option {
+	return func(o *options) {
+		o.earlyExpiryFor = earlyExpiry
+	}
+}
+
+// registryEarlyExpiry returns the early expiry of reg, the longest glob of WithEarlyExpiryFor matching it or the default.
+func (o *options) registryEarlyExpiry(reg *Registry) time.Duration {
+	earlyExpiry, longest := o.earlyExpiry, ""
+	for glob, override := range o.earlyExpiryFor {
+		if len(glob) < len(longest) || (len(glob) == len(longest) && glob > longest) {
+			continue
+		}
+		host, _ := path.Match(glob, reg.String())
+		account, _ := path.Match(glob, reg.AccountID)
+		if host || (account && reg.AccountID != "") {
+			earlyExpiry, longest = override, glob
+		}
+	}
+	return earlyExpiry
+}
+
+// WithRefreshFraction refreshes a cached token once fraction (such as 0.8) of its lifetime has elapsed instead of WithEarlyExpiry before it expires.
+// This adapts to tokens of any lifetime, such as short-lived tokens of an emulated ECR in tests.
+func WithRefreshFraction(fraction float64) Option {
+	return func(o *options) {
+		o.refreshFraction = fraction
+	}
+}
+
+// WithCacheGC sets how often Resolve drops expired tokens from the cache and authenticators not resolved within idleTimeout,
+// so one-off registries don't hold passwords in memory indefinitely. A zero interval disables GC and a zero idleTimeout keeps idle entries.
+func WithCacheGC(interval, idleTimeout time.Duration) Option {
+	return func(o *options) {
+		o.gcInterval = interval
+		o.idleTimeout = idleTimeout
+	}
+}
+
+// WithAuthenticatorMiddleware wraps every authenticator the keychain constructs with middlewares, the first middleware being the outermost.
+// The wrapped authenticators are cached by the keychain. For the ctx of Warm and Lease to reach a middleware, the authenticator it returns
+// must implement AuthorizationContext(ctx context.Context) (*authn.AuthConfig, error) like the authenticators of this package and pass ctx on.
+func WithAuthenticatorMiddleware(middlewares ...AuthenticatorMiddleware) Option {
+	return func(o *options) {
+		o.authenticatorMiddlewares = append(o.authenticatorMiddlewares, middlewares...)
+	}
+}
+
+// WithCache shares the tokens fetched from ECR through cache, keyed by namespace followed by the region (and account if needed) of the registry.
+// A token is only fetched from ECR if cache has none for the key or it is about to expire, errors of cache are logged and otherwise ignored.
+func WithCache(cache Cache, namespace string) Option {
+	return func(o *options) {
+		o.sharedCache = cache
+		o.sharedCacheKey = namespace
+	}
+}
+
+// WithCacheOnly only serves tokens from the Cache of WithCache and never calls GetAuthorizationToken, failing with ErrNotCached instead.
+// This is the consumer side of distributing tokens to workloads that are not allowed to call ecr:GetAuthorizationToken themselves,
+// the publisher being a keychain using the same WithCache without this option.
+func WithCacheOnly() Option {
+	return func(o *options) {
+		o.cacheOnly = true
+	}
+}
+
+// WithConfigRetryBackoff sets how long an error loading the AWS configuration is memoized before the next Resolve loads it again.
+// A zero backoff memoizes the error for the lifetime of the keychain. Defaults to DefaultConfigRetryBackoff.
+func WithConfigRetryBackoff(backoff time.Duration) Option {
+	return func(o *options) {
+		o.configRetryBackoff = backoff
+	}
+}
+
+// WithProfileReload re-checks AWS_PROFILE and the modification times of the shared config and credentials files loaded (such as those of WithSharedConfigFiles) on every Resolve,
+// reloading the AWS configuration and discarding cached authenticators when they change, so `export AWS_PROFILE=prod` takes effect
+// without restarting long-lived tools. It only applies to DefaultKeychain and LazyDefaultKeychain, which load the AWS configuration.
+func WithProfileReload() Option {
+	return func(o *options) {
+		o.profileReload = true
+	}
+}
+
+// WithProfile selects the named profile of the shared config files when DefaultKeychain or LazyDefaultKeychain load the AWS config.
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.loadOptions = append(o.loadOptions, config.WithSharedConfigProfile(profile))
+	}
+}
+
+// WithSharedConfigFiles replaces the locations of the shared config and credentials files DefaultKeychain and LazyDefaultKeychain load,
+// leaving the defaults for whichever of configFiles or credentialsFiles is empty.
+func WithSharedConfigFiles(configFiles, credentialsFiles []string) Option {
+	return func(o *options) {
+		if len(configFiles) > 0 {
+			o.loadOptions = append(o.loadOptions, config.WithSharedConfigFiles(configFiles))
+		}
+		if len(credentialsFiles) > 0 {
+			o.loadOptions = append(o.loadOptions, config.WithSharedCredentialsFiles(credentialsFiles))
+		}
+	}
+}
+
+// WithStrictProxyEndpoint verifies that the ProxyEndpoint returned by GetAuthorizationToken is the registry being resolved.
+// The token is requested for the account of the registry, and a token for another account or region is rejected with ErrProxyEndpointMismatch instead of failing later with a 403 when pulling.
+// It has no effect on ECR Public or on authenticators not created by a Keychain, which do not know their registry.
+func WithStrictProxyEndpoint() Option {
+	return func(o *options) {
+		o.strictProxyEndpoint = true
+	}
+}
+
+// WithDefaultTokenLifetime sets how long a token is assumed to be valid for when GetAuthorizationToken returns no ExpiresAt, DefaultTokenLifetime by default.
+func WithDefaultTokenLifetime(lifetime time.Duration) Option {
+	return func(o *options) {
+		o.defaultTokenLifetime = lifetime
+	}
+}
+
+// discardHandler is a slog.Handler that drops every record, used when no logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
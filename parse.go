@@ -1,13 +1,136 @@
 package ecr
 
 import (
-	"regexp"
+	"fmt"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const ecrPublicDomain = "public.ecr.aws"
 
-var ecrPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(\-fips)?\.([a-zA-Z0-9][a-zA-Z0-9-_]*)\.(amazonaws\.com(?:\.cn)?|sc2s\.sgov\.gov|c2s\.ic\.gov|cloud\.adc-e\.uk|csp\.hci\.ic\.gov)(?:$|/)`)
+// Partition is an AWS partition ECR registries can be hosted in.
+type Partition struct {
+	// Name is the partition identifier, such as aws-iso.
+	Name string
+	// DNSSuffix is the DNS suffix of registry hostnames in the partition, such as c2s.ic.gov.
+	DNSSuffix string
+	// RegionPrefix matches the regions in the partition, such as us-iso-, empty matches any region.
+	RegionPrefix string
+	// Endpoint overrides the ECR API endpoint of registries in the partition, {region} is replaced by the region of the registry.
+	Endpoint string
+	// builtin is set for partitions the AWS SDK resolves the endpoints of.
+	builtin bool
+	// suffixOnly is set for the additional DNS suffixes of RegisterDNSSuffix, which are never used to look up the suffix of a region.
+	suffixOnly bool
+}
+
+var (
+	partitionsMu sync.Mutex
+	// partitions is ordered from most to least specific RegionPrefix.
+	partitions = []Partition{
+		{Name: "aws-cn", DNSSuffix: "amazonaws.com.cn", RegionPrefix: "cn-", builtin: true},
+		{Name: "aws-us-gov", DNSSuffix: "amazonaws.com", RegionPrefix: "us-gov-", builtin: true},
+		{Name: "aws-iso-b", DNSSuffix: "sc2s.sgov.gov", RegionPrefix: "us-isob-", builtin: true},
+		{Name: "aws-iso-f", DNSSuffix: "csp.hci.ic.gov", RegionPrefix: "us-isof-", builtin: true},
+		{Name: "aws-iso-e", DNSSuffix: "cloud.adc-e.uk", RegionPrefix: "eu-isoe-", builtin: true},
+		{Name: "aws-iso", DNSSuffix: "c2s.ic.gov", RegionPrefix: "us-iso-", builtin: true},
+		{Name: "aws", DNSSuffix: "amazonaws.com", builtin: true},
+	}
+	// ecrSuffixes is the DNS suffixes of partitions, read by Parse without holding partitionsMu.
+	ecrSuffixes atomic.Pointer[[]string]
+	// partitionsGeneration is bumped whenever partitions changes, invalidating the hostnames negativeCache knew not to be ECR.
+	partitionsGeneration atomic.Uint64
+)
+
+func init() {
+	ecrSuffixes.Store(dnsSuffixes(partitions))
+}
+
+// dnsSuffixes returns the DNS suffixes of ECR hostnames in any of the given partitions.
+func dnsSuffixes(partitions []Partition) *[]string {
+	suffixes := make([]string, 0, len(partitions))
+	for _, partition := range partitions {
+		suffixes = append(suffixes, partition.DNSSuffix)
+	}
+	return &suffixes
+}
+
+// RegisterPartition adds a partition so Parse recognizes its registries and the keychain can reach its ECR API.
+// It is intended to be called from an init function for partitions released after this package, such as new ISO regions.
+func RegisterPartition(partition Partition) {
+	partitionsMu.Lock()
+	defer partitionsMu.Unlock()
+	partition.builtin = false
+	// Registered partitions take precedence over the builtin ones.
+	partitions = append([]Partition{partition}, partitions...)
+	ecrSuffixes.Store(dnsSuffixes(partitions))
+	partitionsGeneration.Add(1)
+}
+
+// resetPartitions snapshots the partitions, returning a func restoring them for tests that register partitions.
+func resetPartitions() func() {
+	partitionsMu.Lock()
+	defer partitionsMu.Unlock()
+	previous := slices.Clone(partitions)
+	return func() {
+		partitionsMu.Lock()
+		defer partitionsMu.Unlock()
+		partitions = previous
+		ecrSuffixes.Store(dnsSuffixes(partitions))
+		partitionsGeneration.Add(1)
+	}
+}
+
+// DNSSuffixOptions configures a DNS suffix registered with RegisterDNSSuffix.
+type DNSSuffixOptions struct {
+	// RegionPrefix restricts the suffix to the regions with the prefix, empty allows any region.
+	RegionPrefix string
+	// Endpoint overrides the ECR API endpoint as in Partition.
+	Endpoint string
+}
+
+// RegisterDNSSuffix teaches Parse an additional DNS suffix of registries in partition, such as a private suffix resolving to ECR.
+// Unless opts.Endpoint is set a known partition keeps using the ECR API endpoints resolved by the AWS SDK,
+// while an unknown one uses api.ecr.<region>.<suffix> as with RegisterPartition.
+func RegisterDNSSuffix(suffix, partition string, opts DNSSuffixOptions) {
+	partitionsMu.Lock()
+	defer partitionsMu.Unlock()
+	registered := Partition{
+		Name:         partition,
+		DNSSuffix:    suffix,
+		RegionPrefix: opts.RegionPrefix,
+		Endpoint:     opts.Endpoint,
+		suffixOnly:   true,
+	}
+	for _, existing := range partitions {
+		if existing.Name == partition && existing.builtin {
+			registered.builtin = true
+		}
+	}
+	partitions = append([]Partition{registered}, partitions...)
+	ecrSuffixes.Store(dnsSuffixes(partitions))
+	partitionsGeneration.Add(1)
+}
+
+// partitionFor returns the partition of the given region and DNS suffix, an empty DNS suffix matches any.
+func partitionFor(region, dnsSuffix string) (Partition, bool) {
+	partitionsMu.Lock()
+	defer partitionsMu.Unlock()
+	for _, partition := range partitions {
+		if dnsSuffix == "" && partition.suffixOnly {
+			continue
+		}
+		if dnsSuffix != "" && partition.DNSSuffix != dnsSuffix {
+			continue
+		}
+		if strings.HasPrefix(region, partition.RegionPrefix) {
+			return partition, true
+		}
+	}
+	return Partition{}, false
+}
 
 // Registry is a extracted details from a valid ECR hostname.
 type Registry struct {
@@ -28,23 +151,239 @@ func (r *Registry) String() string {
 	return r.AccountID + ".dkr.ecr." + r.Region + "." + r.DNSSuffix
 }
 
+// MarshalText implements encoding.TextMarshaler using the canonical hostname, which also marshals a Registry as a JSON string.
+func (r *Registry) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using Parse.
+func (r *Registry) UnmarshalText(text []byte) error {
+	reg := Parse(string(text))
+	if reg == nil {
+		return fmt.Errorf("%q is not an ECR registry", text)
+	}
+	*r = *reg
+	return nil
+}
+
+// partitionEndpoint returns the ECR API endpoint of reg if it is hosted in a partition with an Endpoint or one unknown to the AWS SDK.
+func partitionEndpoint(reg *Registry) (string, bool) {
+	partition, ok := partitionFor(reg.Region, reg.DNSSuffix)
+	if !ok {
+		return "", false
+	}
+	if partition.Endpoint != "" {
+		return strings.ReplaceAll(partition.Endpoint, "{region}", reg.Region), true
+	}
+	if partition.builtin {
+		return "", false
+	}
+	if reg.FIPS {
+		return "https://ecr-fips." + reg.Region + "." + partition.DNSSuffix, true
+	}
+	return "https://api.ecr." + reg.Region + "." + partition.DNSSuffix, true
+}
+
+// Partition returns the name of the partition the registry is hosted in, or an empty string if it is unknown.
+func (r *Registry) Partition() string {
+	if r.DNSSuffix == ecrPublicDomain {
+		return "aws"
+	}
+	partition, _ := partitionFor(r.Region, r.DNSSuffix)
+	return partition.Name
+}
+
+// NewRegistry returns the private registry of accountID in region, using the DNS suffix of the partition of the region.
+func NewRegistry(accountID, region string) *Registry {
+	return &Registry{AccountID: accountID, Region: region, DNSSuffix: dnsSuffixForRegion(region)}
+}
+
+// dnsSuffixForRegion returns the DNS suffix of the partition the given region belongs to.
+func dnsSuffixForRegion(region string) string {
+	partition, ok := partitionFor(region, "")
+	if !ok {
+		return "amazonaws.com"
+	}
+	return partition.DNSSuffix
+}
+
+// normalize strips the scheme and default port from the host of ref as found in a Docker config.json, such as https://host:443/v2/.
+func normalize(ref string) string {
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://")
+	host, path, found := strings.Cut(ref, "/")
+	normalized := toLowerASCII(strings.TrimSuffix(host, ":443"))
+	// Avoid rebuilding ref when the host is already normalized, which is the common case.
+	if normalized == host {
+		return ref
+	}
+	if !found {
+		return normalized
+	}
+	return normalized + "/" + path
+}
+
+// normalizeHost is like normalize but returns only the host, which does not allocate unless the host has uppercase characters.
+func normalizeHost(ref string) string {
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://")
+	host, _, _ := strings.Cut(ref, "/")
+	return toLowerASCII(strings.TrimSuffix(host, ":443"))
+}
+
+// toLowerASCII lowercases the ASCII letters of s only.
+// Unlike strings.ToLower it leaves lookalikes such as the Kelvin sign (U+212A) alone instead of mapping them to 'k', so they cannot pass as an ECR hostname.
+func toLowerASCII(s string) string {
+	for idx := 0; idx < len(s); idx++ {
+		if c := s[idx]; c >= 'A' && c <= 'Z' {
+			lower := []byte(s)
+			for ; idx < len(lower); idx++ {
+				if c := lower[idx]; c >= 'A' && c <= 'Z' {
+					lower[idx] = c + 'a' - 'A'
+				}
+			}
+			return string(lower)
+		}
+	}
+	return s
+}
+
 // Parse the given ECR hostname extracting the details, returns nil if the reference is not ECR.
+// It does not allocate itself, the Registry is only moved to the heap if the caller retains it.
 func Parse(ref string) *Registry {
-	ref = strings.TrimPrefix(ref, "https://")
-	if ref == ecrPublicDomain || strings.HasPrefix(ref, ecrPublicDomain + "/") {
-		return &Registry{
-			Region:    "us-east-1",
-			DNSSuffix: ecrPublicDomain,
+	reg, ok := parse(ref)
+	if !ok {
+		return nil
+	}
+	return &reg
+}
+
+// parse implements Parse returning the Registry by value, it is kept out of Parse so Parse can be inlined.
+func parse(ref string) (Registry, bool) {
+	host := normalizeHost(ref)
+	if host == ecrPublicDomain {
+		return Registry{Region: "us-east-1", DNSSuffix: ecrPublicDomain}, true
+	}
+	return parseHost(host, *ecrSuffixes.Load())
+}
+
+// ParseStrict is like Parse but only accepts a canonical ECR hostname, rejecting ambiguous ones instead of normalizing them.
+// The hostname must be lowercase and have no scheme, port, path or trailing dot, it can be used for the keychain with WithParser(ParseStrict).
+func ParseStrict(host string) *Registry {
+	if host != normalizeHost(host) {
+		return nil
+	}
+	return Parse(host)
+}
+
+// parseHost matches a normalized <account>.dkr.ecr[-fips].<region>.<suffix> hostname, returns nil if it is not ECR.
+// It is the hot path of Resolve so it slices host in place instead of using a regexp.
+// Every label is matched exactly and the suffix must be the whole rest of host, so lookalikes such as
+// <account>.dkr.ecr.<region>.amazonaws.com.example.com or a trailing dot are rejected.
+func parseHost(host string, suffixes []string) (Registry, bool) {
+	const accountLen = 12
+	if len(host) <= accountLen || host[accountLen] != '.' {
+		return Registry{}, false
+	}
+	for idx := 0; idx < accountLen; idx++ {
+		if host[idx] < '0' || host[idx] > '9' {
+			return Registry{}, false
 		}
 	}
-	matches := ecrPattern.FindStringSubmatch(ref)
-	if matches == nil {
+	rest, ok := strings.CutPrefix(host[accountLen+1:], "dkr.ecr")
+	if !ok {
+		return Registry{}, false
+	}
+	rest, fips := strings.CutPrefix(rest, "-fips")
+	if rest, ok = strings.CutPrefix(rest, "."); !ok {
+		return Registry{}, false
+	}
+	region, suffix, ok := strings.Cut(rest, ".")
+	if !ok || !isRegion(region) {
+		return Registry{}, false
+	}
+	for _, dnsSuffix := range suffixes {
+		if suffix == dnsSuffix {
+			return Registry{
+				AccountID: host[:accountLen],
+				Region:    region,
+				FIPS:      fips,
+				DNSSuffix: dnsSuffix,
+			}, true
+		}
+	}
+	return Registry{}, false
+}
+
+// isRegion reports whether region is a valid region label: an alphanumeric character followed by alphanumerics, '-' or '_'.
+func isRegion(region string) bool {
+	for idx := 0; idx < len(region); idx++ {
+		switch c := region[idx]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case (c == '-' || c == '_') && idx > 0:
+		default:
+			return false
+		}
+	}
+	return region != ""
+}
+
+// ResolveAlias expands a reference starting with one of aliases, such as prod/team/app:tag, using the registry hostname the alias maps to.
+// References not starting with an alias are returned unchanged.
+func ResolveAlias(aliases map[string]string, ref string) string {
+	alias, rest, found := strings.Cut(ref, "/")
+	host, ok := aliases[alias]
+	if !ok {
+		return ref
+	}
+	if !found {
+		return host
+	}
+	return host + "/" + rest
+}
+
+// Reference is a parsed ECR image reference.
+type Reference struct {
+	*Registry
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image reference such as 123456789012.dkr.ecr.us-west-2.amazonaws.com/team/app:tag, returns nil if it is not ECR.
+// For public.ecr.aws references the Repository includes the registry alias, Tag and Digest are empty if not present.
+func ParseReference(ref string) *Reference {
+	reg := Parse(ref)
+	if reg == nil {
 		return nil
 	}
-	return &Registry{
-		AccountID: matches[1],
-		Region:    matches[3],
-		FIPS:      matches[2] == "-fips",
-		DNSSuffix: matches[4],
+	_, rest, _ := strings.Cut(normalize(ref), "/")
+	rest, digest, _ := strings.Cut(rest, "@")
+	repository, tag := rest, ""
+	if idx := strings.LastIndex(rest, ":"); idx > strings.LastIndex(rest, "/") {
+		repository, tag = rest[:idx], rest[idx+1:]
+	}
+	if repository == "" {
+		return nil
+	}
+	return &Reference{Registry: reg, Repository: repository, Tag: tag, Digest: digest}
+}
+
+// IsECR reports whether host is an ECR registry, when it isn't reason explains why.
+func IsECR(host string) (bool, string) {
+	if Parse(host) != nil {
+		return true, ""
+	}
+	host = normalizeHost(host)
+	account, _, found := strings.Cut(host, ".dkr.ecr")
+	switch {
+	case host == "":
+		return false, "host is empty"
+	case strings.HasPrefix(host, "api.ecr.") || strings.HasPrefix(host, "ecr."):
+		return false, "host is the ECR API endpoint rather than a registry"
+	case !found:
+		return false, "host is not a dkr.ecr or public.ecr.aws endpoint"
+	case len(account) != 12 || strings.Trim(account, "0123456789") != "":
+		return false, "account ID is not 12 digits"
+	default:
+		return false, "region or DNS suffix is not a known ECR partition"
 	}
 }
@@ -1,9 +1,11 @@
 package ecr
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParse(t *testing.T) {
@@ -23,7 +25,46 @@ func TestParse(t *testing.T) {
 			FIPS:      true,
 			DNSSuffix: "amazonaws.com",
 		},
-		"invalid.ecr.us-west-2.amazonaws.com": nil,
+		"123456789012.dkr.ecr.us-iso-east-1.c2s.ic.gov": {
+			AccountID: "123456789012",
+			Region:    "us-iso-east-1",
+			DNSSuffix: "c2s.ic.gov",
+		},
+		"123456789012.dkr.ecr.us-isob-east-1.sc2s.sgov.gov": {
+			AccountID: "123456789012",
+			Region:    "us-isob-east-1",
+			DNSSuffix: "sc2s.sgov.gov",
+		},
+		"https://123456789012.dkr.ecr.us-west-2.amazonaws.com:443/v2/": {
+			AccountID: "123456789012",
+			Region:    "us-west-2",
+			DNSSuffix: "amazonaws.com",
+		},
+		"http://123456789012.DKR.ECR.us-west-2.AMAZONAWS.COM": {
+			AccountID: "123456789012",
+			Region:    "us-west-2",
+			DNSSuffix: "amazonaws.com",
+		},
+		"public.ecr.aws:443/docker/library/golang": {
+			Region:    "us-east-1",
+			DNSSuffix: "public.ecr.aws",
+		},
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn/team/app": {
+			AccountID: "123456789012",
+			Region:    "cn-north-1",
+			DNSSuffix: "amazonaws.com.cn",
+		},
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com:5000":    nil,
+		"invalid.ecr.us-west-2.amazonaws.com":                  nil,
+		"12345678901.dkr.ecr.us-west-2.amazonaws.com":          nil,
+		"123456789012.dkr.ecrx.us-west-2.amazonaws.com":        nil,
+		"123456789012.dkr.ecr.-west-2.amazonaws.com":           nil,
+		"123456789012.dkr.ecr.amazonaws.com":                   nil,
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com.evil.io": nil,
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com.":        nil,
+		"evil.io/123456789012.dkr.ecr.us-east-1.amazonaws.com": nil,
+		"123456789012.d\u212Ar.ecr.us-east-1.amazonaws.com":    nil,
+		"public.ecr.aws.evil.io":                               nil,
 	}
 	for host, expected := range tests {
 		host, expected := host, expected
@@ -34,3 +75,237 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSSuffixForRegion(t *testing.T) {
+	tests := map[string]string{
+		"us-west-2":       "amazonaws.com",
+		"cn-north-1":      "amazonaws.com.cn",
+		"us-iso-east-1":   "c2s.ic.gov",
+		"us-isob-east-1":  "sc2s.sgov.gov",
+		"us-isof-south-1": "csp.hci.ic.gov",
+		"eu-isoe-west-1":  "cloud.adc-e.uk",
+	}
+	for region, expected := range tests {
+		region, expected := region, expected
+		t.Run(region, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, expected, dnsSuffixForRegion(region))
+		})
+	}
+}
+
+func TestRegistryPartition(t *testing.T) {
+	tests := map[string]string{
+		"public.ecr.aws": "aws",
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com":      "aws",
+		"123456789012.dkr.ecr.us-gov-west-1.amazonaws.com":  "aws-us-gov",
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":  "aws-cn",
+		"123456789012.dkr.ecr.us-iso-east-1.c2s.ic.gov":     "aws-iso",
+		"123456789012.dkr.ecr.us-isob-east-1.sc2s.sgov.gov": "aws-iso-b",
+	}
+	for host, expected := range tests {
+		host, expected := host, expected
+		t.Run(host, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, expected, Parse(host).Partition())
+		})
+	}
+}
+
+func TestRegisterPartition(t *testing.T) {
+	t.Cleanup(resetPartitions())
+	host := "123456789012.dkr.ecr.xx-test-1.example.test"
+	assert.Nil(t, Parse(host))
+
+	RegisterPartition(Partition{Name: "aws-test", DNSSuffix: "example.test", RegionPrefix: "xx-test-"})
+	reg := Parse(host)
+	assert.Equal(t, &Registry{AccountID: "123456789012", Region: "xx-test-1", DNSSuffix: "example.test"}, reg)
+	assert.Equal(t, "aws-test", reg.Partition())
+	assert.Equal(t, "example.test", dnsSuffixForRegion("xx-test-2"))
+
+	endpoint, ok := partitionEndpoint(reg)
+	assert.True(t, ok)
+	assert.Equal(t, "https://api.ecr.xx-test-1.example.test", endpoint)
+	_, ok = partitionEndpoint(Parse("123456789012.dkr.ecr.us-iso-east-1.c2s.ic.gov"))
+	assert.False(t, ok)
+}
+
+func TestResolveAlias(t *testing.T) {
+	aliases := map[string]string{"prod": "111111111111.dkr.ecr.us-east-1.amazonaws.com"}
+	tests := map[string]string{
+		"prod":                "111111111111.dkr.ecr.us-east-1.amazonaws.com",
+		"prod/team/app:v1":    "111111111111.dkr.ecr.us-east-1.amazonaws.com/team/app:v1",
+		"staging/team/app:v1": "staging/team/app:v1",
+		"production":          "production",
+	}
+	for ref, expected := range tests {
+		ref, expected := ref, expected
+		t.Run(ref, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, expected, ResolveAlias(aliases, ref))
+		})
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := map[string]*Reference{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/team/app:v1": {
+			Registry:   Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+			Repository: "team/app",
+			Tag:        "v1",
+		},
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/app@sha256:abc": {
+			Registry:   Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+			Repository: "app",
+			Digest:     "sha256:abc",
+		},
+		"public.ecr.aws/docker/library/golang:1.22@sha256:abc": {
+			Registry:   Parse("public.ecr.aws"),
+			Repository: "docker/library/golang",
+			Tag:        "1.22",
+			Digest:     "sha256:abc",
+		},
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com": nil,
+		"index.docker.io/library/golang:1.22":          nil,
+	}
+	for ref, expected := range tests {
+		ref, expected := ref, expected
+		t.Run(ref, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, expected, ParseReference(ref))
+		})
+	}
+}
+
+func TestRegistryJSON(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Registry *Registry `json:"registry"`
+	}
+	var cfg config
+	require.NoError(t, json.Unmarshal([]byte(`{"registry":"123456789012.dkr.ecr-fips.us-west-2.amazonaws.com"}`), &cfg))
+	assert.Equal(t, &Registry{AccountID: "123456789012", Region: "us-west-2", FIPS: true, DNSSuffix: "amazonaws.com"}, cfg.Registry)
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"registry":"123456789012.dkr.ecr-fips.us-west-2.amazonaws.com"}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"registry":"index.docker.io"}`), &cfg))
+}
+
+func TestIsECR(t *testing.T) {
+	tests := map[string]string{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com": "",
+		"public.ecr.aws/docker/library/golang":         "",
+		"":                                             "host is empty",
+		"api.ecr.us-west-2.amazonaws.com":              "host is the ECR API endpoint rather than a registry",
+		"index.docker.io":                              "host is not a dkr.ecr or public.ecr.aws endpoint",
+		"1234.dkr.ecr.us-west-2.amazonaws.com":         "account ID is not 12 digits",
+		"123456789012.dkr.ecr.us-west-2.example.com":   "region or DNS suffix is not a known ECR partition",
+	}
+	for host, expected := range tests {
+		host, expected := host, expected
+		t.Run(host, func(t *testing.T) {
+			t.Parallel()
+			ok, reason := IsECR(host)
+			assert.Equal(t, expected == "", ok)
+			assert.Equal(t, expected, reason)
+		})
+	}
+}
+
+func TestRegisterDNSSuffix(t *testing.T) {
+	t.Cleanup(resetPartitions())
+	RegisterDNSSuffix("ecr.internal.example", "aws", DNSSuffixOptions{})
+	reg := Parse("123456789012.dkr.ecr.us-west-2.ecr.internal.example")
+	require.NotNil(t, reg)
+	assert.Equal(t, "aws", reg.Partition())
+	_, ok := partitionEndpoint(reg)
+	assert.False(t, ok)
+	assert.Equal(t, "amazonaws.com", dnsSuffixForRegion("us-west-2"))
+
+	RegisterDNSSuffix("ecr.proxy.example", "aws", DNSSuffixOptions{Endpoint: "https://ecr-api.proxy.example/{region}"})
+	endpoint, ok := partitionEndpoint(Parse("123456789012.dkr.ecr.eu-west-1.ecr.proxy.example"))
+	assert.True(t, ok)
+	assert.Equal(t, "https://ecr-api.proxy.example/eu-west-1", endpoint)
+}
+
+func TestParseStrict(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com":      true,
+		"123456789012.dkr.ecr-fips.us-west-2.amazonaws.com": true,
+		"public.ecr.aws": true,
+		"123456789012.DKR.ECR.us-west-2.amazonaws.com":          false,
+		"https://123456789012.dkr.ecr.us-west-2.amazonaws.com":  false,
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com:443":      false,
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/team/app": false,
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com.":         false,
+		"public.ecr.aws/docker/library/golang":                  false,
+	}
+	for host, valid := range tests {
+		host, valid := host, valid
+		t.Run(host, func(t *testing.T) {
+			t.Parallel()
+			reg := ParseStrict(host)
+			if !valid {
+				assert.Nil(t, reg)
+				return
+			}
+			require.NotNil(t, reg)
+			assert.Equal(t, host, reg.String())
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	f.Add("https://123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com:443/v2/")
+	f.Add("123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn")
+	f.Add("123456789012.dkr.ecr.us-east-1.amazonaws.com.evil.io")
+	f.Add("public.ecr.aws/docker/library/golang")
+	f.Add("index.docker.io")
+	f.Fuzz(func(t *testing.T, ref string) {
+		reg := Parse(ref)
+		if reg == nil {
+			return
+		}
+		// The host of anything Parse accepts must be exactly the canonical hostname of the registry.
+		host := normalizeHost(ref)
+		require.Equal(t, reg.String(), host)
+		require.Equal(t, reg, ParseStrict(host))
+		for idx := 0; idx < len(host); idx++ {
+			require.Less(t, host[idx], byte(0x80), "non-ASCII hostname %q", host)
+		}
+	})
+}
+
+func TestParseAllocs(t *testing.T) {
+	for _, ref := range []string{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		"123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com",
+		"public.ecr.aws",
+		"https://123456789012.dkr.ecr.us-west-2.amazonaws.com:443/v2/",
+		"index.docker.io",
+	} {
+		assert.Zero(t, testing.AllocsPerRun(100, func() { Parse(ref) }), ref)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchmarks := map[string]string{
+		"private": "123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		"fips":    "123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com",
+		"public":  "public.ecr.aws",
+		"url":     "https://123456789012.dkr.ecr.us-west-2.amazonaws.com:443/v2/",
+		"not ecr": "index.docker.io",
+	}
+	for name, ref := range benchmarks {
+		ref := ref
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Parse(ref)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Status is the result of Ping.
+type Status struct {
+	// Reachable is set if the registry responded, Authenticated if it accepted the credentials of the keychain.
+	Reachable     bool
+	Authenticated bool
+	StatusCode    int
+	Latency       time.Duration
+	// Err is the reason the registry is not reachable or authenticated.
+	Err error
+}
+
+// pingClient is the HTTP client of Ping, replaced by tests.
+var pingClient = http.DefaultClient
+
+// Ping performs an authenticated GET against the /v2/ endpoint of registry, such as for the readiness probe of a service pulling from ECR.
+func Ping(ctx context.Context, keychain authn.Keychain, registry string) Status {
+	authenticator, err := resolveContext(ctx, keychain, registryResource(registry))
+	if err != nil {
+		return Status{Err: err}
+	}
+	return ping(ctx, pingClient, authenticator, "https://"+normalizeHost(registry)+"/v2/")
+}
+
+// ping implements Ping against url.
+func ping(ctx context.Context, client *http.Client, authenticator authn.Authenticator, url string) Status {
+	authConfig, err := authorizationContext(ctx, authenticator)
+	if err != nil {
+		return Status{Err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Status{Err: err}
+	}
+	if authConfig.Username != "" || authConfig.Password != "" {
+		req.SetBasicAuth(authConfig.Username, authConfig.Password)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Status{Err: err}
+	}
+	resp.Body.Close()
+	status := Status{
+		Reachable:     true,
+		Authenticated: resp.StatusCode == http.StatusOK,
+		StatusCode:    resp.StatusCode,
+		Latency:       time.Since(start),
+	}
+	if !status.Authenticated {
+		status.Err = fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return status
+}
@@ -0,0 +1,59 @@
+package ecr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, ok := r.BasicAuth(); !ok || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	status := ping(context.Background(), server.Client(), authn.FromConfig(authn.AuthConfig{Username: "AWS", Password: "password"}), server.URL+"/v2/")
+	assert.True(t, status.Reachable)
+	assert.True(t, status.Authenticated)
+	assert.NoError(t, status.Err)
+
+	status = ping(context.Background(), server.Client(), authn.FromConfig(authn.AuthConfig{Username: "AWS", Password: "expired"}), server.URL+"/v2/")
+	assert.True(t, status.Reachable)
+	assert.False(t, status.Authenticated)
+	assert.Equal(t, http.StatusUnauthorized, status.StatusCode)
+	assert.Error(t, status.Err)
+
+	server.Close()
+	status = ping(context.Background(), server.Client(), authn.Anonymous, server.URL+"/v2/")
+	assert.False(t, status.Reachable)
+	assert.Error(t, status.Err)
+}
+
+func TestPingRegistry(t *testing.T) {
+	var paths []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+	client := pingClient
+	pingClient = server.Client()
+	t.Cleanup(func() { pingClient = client })
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour))
+	for _, registry := range []string{host, "https://" + host, "https://" + host + "/v2/", host + "/team/app"} {
+		status := Ping(context.Background(), keychain, registry)
+		assert.True(t, status.Authenticated, registry)
+		assert.NoError(t, status.Err, registry)
+	}
+	assert.Equal(t, []string{"/v2/", "/v2/", "/v2/", "/v2/"}, paths)
+}
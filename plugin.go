@@ -0,0 +1,90 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// PluginSettings are the settings of a Drone or Woodpecker plugin step writing the docker config for subsequent steps.
+type PluginSettings struct {
+	// Registries are the ECR registry hostnames whose credentials are written.
+	Registries []string
+	// Role is the ARN of an IAM role assumed for the registries of every account, the credentials of the aws.Config are used if empty.
+	Role string
+	// Region overrides the region of the AWS configuration, used by the STS calls assuming Role.
+	Region string
+	// ConfigPath is the docker config.json written, .docker/config.json in the workspace if empty.
+	ConfigPath string
+}
+
+// PluginSettingsFromEnv returns the PluginSettings of the PLUGIN_REGISTRIES (comma separated), PLUGIN_ROLE, PLUGIN_REGION and PLUGIN_CONFIG variables of getenv,
+// which is os.Getenv outside of tests. The workspace is CI_WORKSPACE (Woodpecker) or DRONE_WORKSPACE (Drone), the working directory if neither is set.
+func PluginSettingsFromEnv(getenv func(string) string) PluginSettings {
+	settings := PluginSettings{
+		Role:       getenv("PLUGIN_ROLE"),
+		Region:     getenv("PLUGIN_REGION"),
+		ConfigPath: getenv("PLUGIN_CONFIG"),
+	}
+	for _, registry := range strings.Split(getenv("PLUGIN_REGISTRIES"), ",") {
+		if registry = strings.TrimSpace(registry); registry != "" {
+			settings.Registries = append(settings.Registries, registry)
+		}
+	}
+	if settings.ConfigPath == "" {
+		workspace := getenv("CI_WORKSPACE")
+		if workspace == "" {
+			workspace = getenv("DRONE_WORKSPACE")
+		}
+		settings.ConfigPath = filepath.Join(workspace, ".docker", "config.json")
+	}
+	return settings
+}
+
+// Write writes the DockerConfig of the registries of settings to ConfigPath using the credentials of cfg, assuming Role if set.
+func (settings PluginSettings) Write(ctx context.Context, cfg aws.Config, opts ...Option) error {
+	if len(settings.Registries) == 0 {
+		return errors.New("at least one registry is required")
+	}
+	if settings.Region != "" {
+		cfg.Region = settings.Region
+	}
+	opts = opts[:len(opts):len(opts)]
+	for _, registry := range settings.Registries {
+		reg := Parse(registry)
+		if reg == nil {
+			return fmt.Errorf("%q is not an ECR registry", registry)
+		}
+		if settings.Role != "" {
+			opts = append(opts, WithAssumeRole(reg.AccountID, AssumeRole{RoleARN: settings.Role}))
+		}
+	}
+	keychain := NewKeychain(cfg, opts...)
+	if err := keychain.Warm(ctx, settings.Registries...); err != nil {
+		return err
+	}
+	dockerConfig, _, err := NewDockerConfig(keychain, settings.Registries...)
+	if err != nil {
+		return err
+	}
+	return dockerConfig.WriteFile(settings.ConfigPath)
+}
+
+// RunPlugin is the entrypoint of a plugin image, writing the docker config of the PluginSettingsFromEnv of getenv using the default AWS credentials chain.
+func RunPlugin(ctx context.Context, getenv func(string) string, opts ...Option) error {
+	settings := PluginSettingsFromEnv(getenv)
+	loadOptions := newOptions(opts).loadOptions
+	if settings.Region != "" {
+		loadOptions = append(loadOptions[:len(loadOptions):len(loadOptions)], config.WithRegion(settings.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return err
+	}
+	return settings.Write(ctx, cfg, opts...)
+}
@@ -0,0 +1,119 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginSettingsFromEnv(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		env      map[string]string
+		expected PluginSettings
+	}{
+		"drone": {
+			env: map[string]string{
+				"PLUGIN_REGISTRIES": "123456789012.dkr.ecr.us-west-2.amazonaws.com, 210987654321.dkr.ecr.eu-west-1.amazonaws.com,",
+				"PLUGIN_ROLE":       "arn:aws:iam::123456789012:role/ecr-push",
+				"PLUGIN_REGION":     "us-west-2",
+				"DRONE_WORKSPACE":   "/drone/src",
+			},
+			expected: PluginSettings{
+				Registries: []string{"123456789012.dkr.ecr.us-west-2.amazonaws.com", "210987654321.dkr.ecr.eu-west-1.amazonaws.com"},
+				Role:       "arn:aws:iam::123456789012:role/ecr-push",
+				Region:     "us-west-2",
+				ConfigPath: "/drone/src/.docker/config.json",
+			},
+		},
+		"woodpecker": {
+			env: map[string]string{
+				"PLUGIN_REGISTRIES": "123456789012.dkr.ecr.us-west-2.amazonaws.com",
+				"CI_WORKSPACE":      "/woodpecker/src",
+				"DRONE_WORKSPACE":   "/drone/src",
+			},
+			expected: PluginSettings{
+				Registries: []string{"123456789012.dkr.ecr.us-west-2.amazonaws.com"},
+				ConfigPath: "/woodpecker/src/.docker/config.json",
+			},
+		},
+		"config": {
+			env: map[string]string{
+				"PLUGIN_CONFIG":   "/root/.docker/config.json",
+				"DRONE_WORKSPACE": "/drone/src",
+			},
+			expected: PluginSettings{
+				ConfigPath: "/root/.docker/config.json",
+			},
+		},
+		"workdir": {
+			expected: PluginSettings{
+				ConfigPath: ".docker/config.json",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			getenv := func(key string) string { return tt.env[key] }
+			assert.Equal(t, tt.expected, PluginSettingsFromEnv(getenv))
+		})
+	}
+}
+
+func TestPluginSettingsWrite(t *testing.T) {
+	t.Parallel()
+	stsServer := newSTSServer(t)
+	ecrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"authorizationData": []map[string]any{{
+				"authorizationToken": "QVdTOnBhc3N3b3Jk",
+				"expiresAt":          time.Now().Add(12 * time.Hour).Unix(),
+			}},
+		})
+	}))
+	t.Cleanup(ecrServer.Close)
+
+	path := filepath.Join(t.TempDir(), ".docker", "config.json")
+	err := PluginSettings{
+		Registries: []string{"123456789012.dkr.ecr.us-west-2.amazonaws.com", "210987654321.dkr.ecr.eu-west-1.amazonaws.com"},
+		Role:       "arn:aws:iam::123456789012:role/ecr-push",
+		Region:     "us-west-2",
+		ConfigPath: path,
+	}.Write(context.Background(), aws.Config{
+		BaseEndpoint: aws.String(stsServer.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIABASE", "secret", ""),
+	}, WithBaseEndpoint(ecrServer.URL))
+	require.NoError(t, err)
+	for _, request := range stsServer.Requests() {
+		assert.Equal(t, "arn:aws:iam::123456789012:role/ecr-push", request.PostForm.Get("RoleArn"))
+	}
+	assert.Len(t, stsServer.Requests(), 2)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var dockerConfig DockerConfig
+	require.NoError(t, json.Unmarshal(b, &dockerConfig))
+	auth := DockerConfigAuth{Auth: base64.StdEncoding.EncodeToString([]byte("AWS:password"))}
+	assert.Equal(t, map[string]DockerConfigAuth{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com": auth,
+		"210987654321.dkr.ecr.eu-west-1.amazonaws.com": auth,
+	}, dockerConfig.Auths)
+
+	err = PluginSettings{Registries: []string{"index.docker.io"}, ConfigPath: path}.Write(context.Background(), aws.Config{})
+	assert.Error(t, err)
+	err = PluginSettings{ConfigPath: path}.Write(context.Background(), aws.Config{})
+	assert.Error(t, err)
+}
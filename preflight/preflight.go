@@ -0,0 +1,80 @@
+// Package preflight checks whether an AWS caller has the IAM permissions to pull from or push to an ECR repository.
+// It is separate from the ecr package so only callers of Check depend on the IAM client.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// PullActions are the IAM actions required to pull from a repository.
+var PullActions = []string{"ecr:GetAuthorizationToken", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}
+
+// PushActions are the IAM actions required in addition to PullActions to push to a repository.
+var PushActions = []string{"ecr:BatchCheckLayerAvailability", "ecr:InitiateLayerUpload", "ecr:UploadLayerPart", "ecr:CompleteLayerUpload", "ecr:PutImage"}
+
+// DeniedAction is an action Check found the caller is not allowed to perform.
+type DeniedAction struct {
+	Action string
+	// Decision is either implicitDeny (no policy allows it) or explicitDeny.
+	Decision string
+}
+
+// Check simulates the PullActions (and PushActions if push is set) on repositoryARN with iam:SimulatePrincipalPolicy,
+// returning the actions the caller of cfg is denied. This requires the iam:SimulatePrincipalPolicy permission and
+// only evaluates identity based policies, assumed roles are simulated as the role without its path.
+func Check(ctx context.Context, cfg aws.Config, repositoryARN string, push bool) ([]DeniedAction, error) {
+	return check(ctx, sts.NewFromConfig(cfg), iam.NewFromConfig(cfg), repositoryARN, push)
+}
+
+// callerIdentityClient is the subset of *sts.Client used by check.
+type callerIdentityClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// check implements Check using the given clients.
+func check(ctx context.Context, stsClient callerIdentityClient, iamClient iam.SimulatePrincipalPolicyAPIClient, repositoryARN string, push bool) ([]DeniedAction, error) {
+	identity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("(*sts.Client).GetCallerIdentity failed: %w", err)
+	}
+	actions := PullActions
+	if push {
+		actions = append(append([]string(nil), PullActions...), PushActions...)
+	}
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(iamClient, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principalARN(aws.ToString(identity.Arn))),
+		ActionNames:     actions,
+		ResourceArns:    []string{repositoryARN},
+	})
+	var denied []DeniedAction
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("(*iam.Client).SimulatePrincipalPolicy failed: %w", err)
+		}
+		for _, result := range out.EvaluationResults {
+			if result.EvalDecision != types.PolicyEvaluationDecisionTypeAllowed {
+				denied = append(denied, DeniedAction{Action: aws.ToString(result.EvalActionName), Decision: string(result.EvalDecision)})
+			}
+		}
+	}
+	return denied, nil
+}
+
+// principalARN returns the IAM ARN to simulate the policies of for the caller identity ARN.
+// An assumed role session such as arn:aws:sts::123456789012:assumed-role/name/session maps to arn:aws:iam::123456789012:role/name.
+func principalARN(callerARN string) string {
+	parts := strings.SplitN(callerARN, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return callerARN
+	}
+	role := strings.Split(strings.TrimPrefix(parts[5], "assumed-role/"), "/")[0]
+	return "arn:" + parts[1] + ":iam::" + parts[4] + ":role/" + role
+}
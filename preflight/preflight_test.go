@@ -0,0 +1,57 @@
+package preflight
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCallerIdentity implements callerIdentityClient returning a fixed ARN, the account is taken from the ARN.
+type fakeCallerIdentity string
+
+func (arn fakeCallerIdentity) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Arn:     aws.String(string(arn)),
+		Account: aws.String(strings.Split(string(arn), ":")[4]),
+	}, nil
+}
+
+// fakeSimulatePrincipalPolicy implements iam.SimulatePrincipalPolicyAPIClient allowing the actions in allowed.
+type fakeSimulatePrincipalPolicy struct {
+	allowed map[string]bool
+	input   *iam.SimulatePrincipalPolicyInput
+}
+
+func (f *fakeSimulatePrincipalPolicy) SimulatePrincipalPolicy(ctx context.Context, params *iam.SimulatePrincipalPolicyInput, optFns ...func(*iam.Options)) (*iam.SimulatePrincipalPolicyOutput, error) {
+	f.input = params
+	out := &iam.SimulatePrincipalPolicyOutput{}
+	for _, action := range params.ActionNames {
+		decision := types.PolicyEvaluationDecisionTypeImplicitDeny
+		if f.allowed[action] {
+			decision = types.PolicyEvaluationDecisionTypeAllowed
+		}
+		out.EvaluationResults = append(out.EvaluationResults, types.EvaluationResult{EvalActionName: aws.String(action), EvalDecision: decision})
+	}
+	return out, nil
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	simulator := &fakeSimulatePrincipalPolicy{allowed: map[string]bool{"ecr:GetAuthorizationToken": true, "ecr:BatchGetImage": true}}
+	denied, err := check(context.Background(), fakeCallerIdentity("arn:aws:sts::123456789012:assumed-role/ci/session"), simulator, "arn:aws:ecr:us-west-2:123456789012:repository/app", false)
+	require.NoError(t, err)
+	assert.Equal(t, []DeniedAction{{Action: "ecr:GetDownloadUrlForLayer", Decision: "implicitDeny"}}, denied)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/ci", aws.ToString(simulator.input.PolicySourceArn))
+
+	denied, err = check(context.Background(), fakeCallerIdentity("arn:aws:iam::123456789012:user/dev"), simulator, "arn:aws:ecr:us-west-2:123456789012:repository/app", true)
+	require.NoError(t, err)
+	assert.Len(t, denied, 1+len(PushActions))
+	assert.Equal(t, "arn:aws:iam::123456789012:user/dev", aws.ToString(simulator.input.PolicySourceArn))
+}
@@ -0,0 +1,47 @@
+package ecr
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// profileFingerprint summarizes the AWS profile and the modification times of the shared config files the keychain loads.
+func (o *options) profileFingerprint() string {
+	source := o.sharedConfig()
+	var fingerprint strings.Builder
+	fmt.Fprintf(&fingerprint, "profile=%s\n", source.profile)
+	for _, path := range append(slices.Clip(source.configFiles), source.credentialsFiles...) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&fingerprint, "%s %d %d\n", path, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&fingerprint, "%s missing\n", path)
+		}
+	}
+	return fingerprint.String()
+}
+
+// checkProfile discards the loaded AWS configuration and cached authenticators with WithProfileReload if the profile fingerprint changed.
+func (keychain *ecrKeychain) checkProfile() {
+	if !keychain.opts.profileReload {
+		return
+	}
+	fingerprint := keychain.opts.profileFingerprint()
+	previous := keychain.profile.Load()
+	if previous == nil {
+		keychain.profile.CompareAndSwap(nil, &fingerprint)
+		return
+	}
+	if *previous == fingerprint || !keychain.profile.CompareAndSwap(previous, &fingerprint) {
+		return
+	}
+	keychain.opts.logger.Debug("AWS profile or shared config files changed, reloading AWS config")
+	keychain.cfgMu.Lock()
+	keychain.cfg.Store(nil)
+	keychain.cfgMu.Unlock()
+	if !keychain.opts.sharedPool {
+		keychain.cache.Store(new(sync.Map))
+	}
+}
@@ -0,0 +1,73 @@
+package ecr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychainProfileReload(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(credentials, []byte("[dev]\n"), 0o600))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_PROFILE", "dev")
+
+	var loads atomic.Int32
+	keychain := newKeychain(func(context.Context) (aws.Config, error) {
+		loads.Add(1)
+		return aws.Config{Region: "us-west-2"}, nil
+	}, []Option{WithProfileReload()})
+	resolve := func() {
+		_, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+		require.NoError(t, err)
+	}
+	resolve()
+	resolve()
+	assert.EqualValues(t, 1, loads.Load())
+
+	t.Setenv("AWS_PROFILE", "prod")
+	resolve()
+	assert.EqualValues(t, 2, loads.Load())
+
+	require.NoError(t, os.Chtimes(credentials, time.Now(), time.Now().Add(time.Hour)))
+	resolve()
+	assert.EqualValues(t, 3, loads.Load())
+}
+
+func TestKeychainProfileReloadSharedConfigFiles(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentials := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(configFile, []byte("[default]\n"), 0o600))
+
+	var loads atomic.Int32
+	keychain := newKeychain(func(context.Context) (aws.Config, error) {
+		loads.Add(1)
+		return aws.Config{Region: "us-west-2"}, nil
+	}, []Option{WithProfileReload(), WithSharedConfigFiles([]string{configFile}, []string{credentials})})
+	resolve := func() {
+		_, err := keychain.Resolve(registryResource("123456789012.dkr.ecr.us-west-2.amazonaws.com"))
+		require.NoError(t, err)
+	}
+	resolve()
+	resolve()
+	assert.EqualValues(t, 1, loads.Load())
+
+	require.NoError(t, os.Chtimes(configFile, time.Now(), time.Now().Add(time.Hour)))
+	resolve()
+	assert.EqualValues(t, 2, loads.Load())
+
+	require.NoError(t, os.WriteFile(credentials, []byte("[default]\n"), 0o600))
+	resolve()
+	assert.EqualValues(t, 3, loads.Load())
+}
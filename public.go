@@ -0,0 +1,48 @@
+package ecr
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecrpublic"
+)
+
+// DefaultECRPublicRegion is the only region serving ecr-public:GetAuthorizationToken.
+const DefaultECRPublicRegion = "us-east-1"
+
+// newPublicClient returns a new ECR Public client pinned to the configured ECR Public region.
+func (keychain *ecrKeychain) newPublicClient(cfg aws.Config, reg *Registry, optFns ...func(*ecrpublic.Options)) *ecrpublic.Client {
+	configure := func(opts *ecrpublic.Options) {
+		opts.Region = keychain.opts.ecrPublicRegion
+		opts.Credentials = keychain.credentials(cfg, reg)
+		if keychain.opts.httpClient != nil {
+			opts.HTTPClient = keychain.opts.httpClient
+		}
+		if keychain.opts.baseEndpoint != "" {
+			opts.BaseEndpoint = aws.String(keychain.opts.baseEndpoint)
+		}
+		opts.APIOptions = append(opts.APIOptions, addUserAgent)
+		opts.APIOptions = append(opts.APIOptions, keychain.opts.apiOptions...)
+	}
+	return ecrpublic.NewFromConfig(cfg, append([]func(*ecrpublic.Options){configure}, optFns...)...)
+}
+
+// publicTokenFunc adapts ecr-public:GetAuthorizationToken to the ecrClient interface.
+func publicTokenFunc(client *ecrpublic.Client) funcGetAuthorizationToken {
+	return func(ctx context.Context, _ *ecr.GetAuthorizationTokenInput, _ ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+		out, err := client.GetAuthorizationToken(ctx, &ecrpublic.GetAuthorizationTokenInput{})
+		if err != nil {
+			return nil, err
+		}
+		output := &ecr.GetAuthorizationTokenOutput{ResultMetadata: out.ResultMetadata}
+		if out.AuthorizationData != nil {
+			output.AuthorizationData = []types.AuthorizationData{{
+				AuthorizationToken: out.AuthorizationData.AuthorizationToken,
+				ExpiresAt:          out.AuthorizationData.ExpiresAt,
+			}}
+		}
+		return output, nil
+	}
+}
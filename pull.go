@@ -0,0 +1,25 @@
+package ecr
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Pull parses ref and pulls the image with remote.Image, authenticating with the package-level keychain of Resolve.
+// It uses the RemoteOptions of the keychain so requests rejected with a 401 are retried with a new token, options are applied after them.
+func Pull(ctx context.Context, ref string, options ...remote.Option) (v1.Image, error) {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	return remote.Image(parsed, remoteOptions(ctx, getDefaultKeychain(), options)...)
+}
+
+// remoteOptions returns the RemoteOptions of keychain bound to ctx followed by options.
+func remoteOptions(ctx context.Context, keychain authn.Keychain, options []remote.Option) []remote.Option {
+	return append(append(RemoteOptions(keychain), remote.WithContext(ctx)), options...)
+}
@@ -0,0 +1,39 @@
+package ecr
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPull(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	ref := strings.TrimPrefix(server.URL, "http://") + "/team/app:latest"
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(parsed, img))
+
+	pulled, err := Pull(context.Background(), ref)
+	require.NoError(t, err)
+	expected, err := img.Digest()
+	require.NoError(t, err)
+	actual, err := pulled.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	_, err = Pull(context.Background(), "invalid reference")
+	assert.Error(t, err)
+}
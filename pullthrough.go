@@ -0,0 +1,71 @@
+package ecr
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// DefaultPullThroughCachePrefixes maps upstream registries to the repository prefixes ECR suggests for their pull through cache rules.
+var DefaultPullThroughCachePrefixes = map[string]string{
+	"docker.io":       "docker-hub",
+	"quay.io":         "quay",
+	"ghcr.io":         "github",
+	"registry.k8s.io": "k8s",
+	"public.ecr.aws":  "ecr-public",
+}
+
+// PullThroughCache rewrites references to upstream registries into references to an ECR pull through cache.
+type PullThroughCache struct {
+	keychain authn.Keychain
+	registry *Registry
+	prefixes map[string]string
+}
+
+// NewPullThroughCache returns a PullThroughCache for the ECR registry hostname, using DefaultPullThroughCachePrefixes if prefixes is nil.
+func NewPullThroughCache(keychain authn.Keychain, registry string, prefixes map[string]string) (*PullThroughCache, error) {
+	reg := Parse(registry)
+	if reg == nil || reg.DNSSuffix == ecrPublicDomain {
+		return nil, fmt.Errorf("%q is not a private ECR registry", registry)
+	}
+	if prefixes == nil {
+		prefixes = DefaultPullThroughCachePrefixes
+	}
+	ptc := &PullThroughCache{
+		keychain: keychain,
+		registry: reg,
+		prefixes: make(map[string]string, len(prefixes)),
+	}
+	for upstream, prefix := range prefixes {
+		// Normalize the upstream so docker.io matches the index.docker.io of parsed references.
+		upstreamRegistry, err := name.NewRegistry(upstream)
+		if err != nil {
+			return nil, err
+		}
+		ptc.prefixes[upstreamRegistry.RegistryStr()] = prefix
+	}
+	return ptc, nil
+}
+
+// Rewrite returns the pull through cache reference for ref, or ref itself if its registry has no cache rule.
+func (ptc *PullThroughCache) Rewrite(ref name.Reference) (name.Reference, error) {
+	prefix, ok := ptc.prefixes[ref.Context().RegistryStr()]
+	if !ok {
+		return ref, nil
+	}
+	return rewriteRepository(ref, ptc.registry.String()+"/"+prefix+"/"+ref.Context().RepositoryStr())
+}
+
+// Resolve rewrites ref and returns the authenticator for the rewritten reference.
+func (ptc *PullThroughCache) Resolve(ref name.Reference) (name.Reference, authn.Authenticator, error) {
+	ref, err := ptc.Rewrite(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	authenticator, err := ptc.keychain.Resolve(ref.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, authenticator, nil
+}
@@ -0,0 +1,40 @@
+package ecr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullThroughCache(t *testing.T) {
+	t.Parallel()
+	keychain := newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour))
+	ptc, err := NewPullThroughCache(keychain, "123456789012.dkr.ecr.us-west-2.amazonaws.com", nil)
+	require.NoError(t, err)
+	tests := map[string]string{
+		"nginx:latest":                                        "123456789012.dkr.ecr.us-west-2.amazonaws.com/docker-hub/library/nginx:latest",
+		"quay.io/prometheus/prometheus:v2":                    "123456789012.dkr.ecr.us-west-2.amazonaws.com/quay/prometheus/prometheus:v2",
+		"ghcr.io/org/app@sha256:" + digest:                    "123456789012.dkr.ecr.us-west-2.amazonaws.com/github/org/app@sha256:" + digest,
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1": "123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1",
+	}
+	for ref, expected := range tests {
+		ref, expected := ref, expected
+		t.Run(ref, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := name.ParseReference(ref)
+			require.NoError(t, err)
+			rewritten, authenticator, err := ptc.Resolve(parsed)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rewritten.String())
+			authConfig, err := authenticator.Authorization()
+			require.NoError(t, err)
+			assert.Equal(t, "password", authConfig.Password)
+		})
+	}
+
+	_, err = NewPullThroughCache(keychain, "index.docker.io", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// repositoryCreator is the subset of *ecr.Client used by Write.
+type repositoryCreator interface {
+	CreateRepository(ctx context.Context, params *ecr.CreateRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryOutput, error)
+}
+
+// Write is like remote.Write authenticating with keychain, but creates the ECR repository and retries if it does not exist.
+// The repository is created with the tags, encryption and scanning settings of input (which may be nil), its RepositoryName and RegistryId are set from ref.
+func Write(ctx context.Context, cfg aws.Config, keychain authn.Keychain, ref name.Reference, img v1.Image, input *ecr.CreateRepositoryInput, options ...remote.Option) error {
+	reg, err := privateRegistry(ref)
+	if err != nil {
+		return err
+	}
+	options = append([]remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain)}, options...)
+	return write(ctx, newRepositoryClient(cfg, reg), reg, ref, img, input, nil, options)
+}
+
+// newRepositoryClient returns an ECR client creating repositories of reg with cfg.
+func newRepositoryClient(cfg aws.Config, reg *Registry) *ecr.Client {
+	return ecr.NewFromConfig(cfg, func(o *ecr.Options) {
+		o.Region = reg.Region
+		if reg.FIPS {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+	})
+}
+
+// write implements Write using client, forwarding the upload progress to progress if it is not nil.
+func write(ctx context.Context, client repositoryCreator, reg *Registry, ref name.Reference, img v1.Image, input *ecr.CreateRepositoryInput, progress func(v1.Update), options []remote.Option) error {
+	return writeCreatingRepository(ctx, client, reg, ref.Context().RepositoryStr(), input, func() error {
+		return writeProgress(ref, img, progress, true, options)
+	})
+}
+
+// privateRegistry returns the registry of ref, which must be a private ECR registry.
+func privateRegistry(ref name.Reference) (*Registry, error) {
+	reg := Parse(ref.Context().RegistryStr())
+	if reg == nil || reg.DNSSuffix == ecrPublicDomain {
+		return nil, fmt.Errorf("%q is not a private ECR registry", ref.Context().RegistryStr())
+	}
+	return reg, nil
+}
+
+// PushOptions configures Push.
+type PushOptions struct {
+	// CreateRepository creates the repository with its tags, encryption and scanning settings as with Write if it does not exist, when not nil.
+	CreateRepository *ecr.CreateRepositoryInput
+	// Config is the AWS config creating the repository as with Write, such as when the package-level keychain is wrapped with Chain.
+	// If nil the config of the package-level keychain is used, which must then be a Keychain of this package.
+	Config *aws.Config
+	// Progress is called from another goroutine with the upload progress, before Push returns.
+	Progress func(update v1.Update)
+}
+
+// Push parses ref and pushes img with remote.Write, authenticating with the package-level keychain of Resolve.
+// As with Pull the RemoteOptions of the keychain are used, options are applied after them.
+func Push(ctx context.Context, ref string, img v1.Image, opts PushOptions, options ...remote.Option) error {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	keychain := getDefaultKeychain()
+	options = remoteOptions(ctx, keychain, options)
+	if opts.CreateRepository == nil {
+		return writeProgress(parsed, img, opts.Progress, false, options)
+	}
+	reg, err := privateRegistry(parsed)
+	if err != nil {
+		return err
+	}
+	if opts.Config != nil {
+		return write(ctx, newRepositoryClient(*opts.Config, reg), reg, parsed, img, opts.CreateRepository, opts.Progress, options)
+	}
+	configured, ok := keychain.(*ecrKeychain)
+	if !ok {
+		return errors.New("PushOptions.CreateRepository requires PushOptions.Config unless the package-level keychain is a Keychain of this package")
+	}
+	cfg, err := configured.configFor(ctx, reg)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return write(ctx, configured.newClient(cfg, reg), reg, parsed, img, opts.CreateRepository, opts.Progress, options)
+}
+
+// writeProgress calls remote.Write forwarding the upload progress to progress if it is not nil, with a channel per call as remote.Write closes it.
+// The failure that makes the repository be created is not reported when skipNotFound is set, the write is retried.
+func writeProgress(ref name.Reference, img v1.Image, progress func(v1.Update), skipNotFound bool, options []remote.Option) error {
+	if progress == nil {
+		return remote.Write(ref, img, options...)
+	}
+	// remote.Write only closes the progress channel if the options are valid, check them first so the forwarding goroutine always exits.
+	if _, err := remote.NewPusher(options...); err != nil {
+		return err
+	}
+	updates := make(chan v1.Update, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for update := range updates {
+			if skipNotFound && isRepositoryNotFound(update.Error) {
+				continue
+			}
+			progress(update)
+		}
+	}()
+	defer func() { <-done }()
+	return remote.Write(ref, img, append(options[:len(options):len(options)], remote.WithProgress(updates))...)
+}
+
+// writeCreatingRepository calls write, creating the repository and calling write again if it failed because the repository does not exist.
+func writeCreatingRepository(ctx context.Context, client repositoryCreator, reg *Registry, repository string, input *ecr.CreateRepositoryInput, write func() error) error {
+	err := write()
+	if !isRepositoryNotFound(err) {
+		return err
+	}
+	params := &ecr.CreateRepositoryInput{}
+	if input != nil {
+		clone := *input
+		params = &clone
+	}
+	params.RepositoryName = aws.String(repository)
+	params.RegistryId = aws.String(reg.AccountID)
+	if _, err := client.CreateRepository(ctx, params); err != nil {
+		// Another writer may have created the repository concurrently.
+		var exists *types.RepositoryAlreadyExistsException
+		if !errors.As(err, &exists) {
+			return fmt.Errorf("(*ecr.Client).CreateRepository failed: %w", err)
+		}
+	}
+	return write()
+}
+
+// isRepositoryNotFound reports whether err is the registry error returned when pushing to a repository that does not exist.
+func isRepositoryNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	for _, diagnostic := range terr.Errors {
+		if diagnostic.Code == transport.NameUnknownErrorCode {
+			return true
+		}
+	}
+	return false
+}
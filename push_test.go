@@ -0,0 +1,155 @@
+package ecr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepositoryCreator implements repositoryCreator recording the repositories created.
+type fakeRepositoryCreator struct {
+	created []*ecr.CreateRepositoryInput
+	err     error
+}
+
+func (c *fakeRepositoryCreator) CreateRepository(ctx context.Context, params *ecr.CreateRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryOutput, error) {
+	c.created = append(c.created, params)
+	return &ecr.CreateRepositoryOutput{}, c.err
+}
+
+func TestWriteCreatingRepository(t *testing.T) {
+	t.Parallel()
+	reg := Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com")
+	input := &ecr.CreateRepositoryInput{ImageTagMutability: types.ImageTagMutabilityImmutable}
+	notFound := &transport.Error{Errors: []transport.Diagnostic{{Code: transport.NameUnknownErrorCode}}}
+	tests := map[string]struct {
+		errs      []error
+		createErr error
+		created   int
+		err       bool
+	}{
+		"exists":      {errs: []error{nil}},
+		"created":     {errs: []error{notFound, nil}, created: 1},
+		"race":        {errs: []error{notFound, nil}, createErr: &types.RepositoryAlreadyExistsException{}, created: 1},
+		"create fail": {errs: []error{notFound}, createErr: &types.InvalidParameterException{}, created: 1, err: true},
+		"other error": {errs: []error{&transport.Error{Errors: []transport.Diagnostic{{Code: transport.DeniedErrorCode}}}}, err: true},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client := &fakeRepositoryCreator{err: tt.createErr}
+			var writes int
+			err := writeCreatingRepository(context.Background(), client, reg, "team/app", input, func() error {
+				writes++
+				return tt.errs[writes-1]
+			})
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, client.created, tt.created)
+			for _, created := range client.created {
+				assert.Equal(t, "team/app", aws.ToString(created.RepositoryName))
+				assert.Equal(t, "123456789012", aws.ToString(created.RegistryId))
+				assert.Equal(t, types.ImageTagMutabilityImmutable, created.ImageTagMutability)
+			}
+		})
+	}
+	assert.Nil(t, input.RepositoryName)
+}
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	ref := strings.TrimPrefix(server.URL, "http://") + "/team/app:latest"
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	var last v1.Update
+	require.NoError(t, Push(context.Background(), ref, img, PushOptions{
+		Progress: func(update v1.Update) {
+			require.NoError(t, update.Error)
+			last = update
+		},
+	}))
+	assert.Positive(t, last.Total)
+	assert.Equal(t, last.Total, last.Complete)
+
+	pulled, err := Pull(context.Background(), ref)
+	require.NoError(t, err)
+	expected, err := img.Digest()
+	require.NoError(t, err)
+	actual, err := pulled.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	err = Push(context.Background(), ref, img, PushOptions{CreateRepository: &ecr.CreateRepositoryInput{}})
+	assert.ErrorContains(t, err, "is not a private ECR registry")
+	err = Push(context.Background(), ref, img, PushOptions{Progress: func(v1.Update) {}}, remote.WithJobs(0))
+	assert.Error(t, err)
+}
+
+// rewriteTransport sends every request to host over plain HTTP.
+type rewriteTransport string
+
+func (host rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = "http", string(host)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestPushConfig(t *testing.T) {
+	t.Cleanup(func() { SetDefaultKeychain(nil) })
+	var created atomic.Bool
+	handler := registry.New()
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !created.Load() && r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"NAME_UNKNOWN","message":"repository does not exist"}]}`))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(registryServer.Close)
+	ecrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AmazonEC2ContainerRegistry_V20150921.CreateRepository", r.Header.Get("X-Amz-Target"))
+		created.Store(true)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte(`{"repository":{}}`))
+	}))
+	t.Cleanup(ecrServer.Close)
+
+	// The package-level keychain is wrapped so it is not a Keychain of this package, the repository is created with Config instead.
+	SetDefaultKeychain(Chain(newTestKeychain("us-west-2/false", newFakeClient("AWS", "password", 12*time.Hour)), AllowlistMiddleware("*.amazonaws.com")))
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, Push(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com/team/app:v1", img, PushOptions{
+		CreateRepository: &ecr.CreateRepositoryInput{},
+		Config: &aws.Config{
+			Region:       "us-west-2",
+			BaseEndpoint: aws.String(ecrServer.URL),
+			Credentials:  credentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""),
+		},
+	}, remote.WithTransport(rewriteTransport(strings.TrimPrefix(registryServer.URL, "http://")))))
+	assert.True(t, created.Load())
+}
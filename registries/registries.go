@@ -0,0 +1,51 @@
+// Package registries lists the ECR registries an AWS caller can authenticate to.
+// It is separate from the ecr package so only callers of List depend on the EC2 client.
+package registries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+)
+
+// callerIdentityClient is the subset of *sts.Client used by list.
+type callerIdentityClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// describeRegionsClient is the subset of *ec2.Client used by list.
+type describeRegionsClient interface {
+	DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+}
+
+// List returns the registries the caller of cfg can authenticate to: its own private registry in each region plus public.ecr.aws.
+// If no regions are provided the regions enabled for the account are discovered using ec2:DescribeRegions.
+func List(ctx context.Context, cfg aws.Config, regions ...string) ([]*ecr.Registry, error) {
+	return list(ctx, sts.NewFromConfig(cfg), ec2.NewFromConfig(cfg), regions)
+}
+
+// list implements List using the given clients.
+func list(ctx context.Context, stsClient callerIdentityClient, ec2Client describeRegionsClient, regions []string) ([]*ecr.Registry, error) {
+	identity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("(*sts.Client).GetCallerIdentity failed: %w", err)
+	}
+	if len(regions) == 0 {
+		out, err := ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+		if err != nil {
+			return nil, fmt.Errorf("(*ec2.Client).DescribeRegions failed: %w", err)
+		}
+		for _, region := range out.Regions {
+			regions = append(regions, aws.ToString(region.RegionName))
+		}
+	}
+	registries := make([]*ecr.Registry, 0, len(regions)+1)
+	for _, region := range regions {
+		registries = append(registries, ecr.NewRegistry(aws.ToString(identity.Account), region))
+	}
+	return append(registries, ecr.Parse("public.ecr.aws")), nil
+}
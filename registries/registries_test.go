@@ -0,0 +1,57 @@
+package registries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCallerIdentity implements callerIdentityClient returning a fixed account.
+type fakeCallerIdentity string
+
+func (account fakeCallerIdentity) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String(string(account))}, nil
+}
+
+// fakeDescribeRegions implements describeRegionsClient returning fixed regions.
+type fakeDescribeRegions struct {
+	regions []string
+	calls   int
+}
+
+func (f *fakeDescribeRegions) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	f.calls++
+	out := &ec2.DescribeRegionsOutput{}
+	for _, region := range f.regions {
+		out.Regions = append(out.Regions, types.Region{RegionName: aws.String(region)})
+	}
+	return out, nil
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+	regions := &fakeDescribeRegions{regions: []string{"us-west-2", "eu-west-1"}}
+	registries, err := list(context.Background(), fakeCallerIdentity("123456789012"), regions, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*ecr.Registry{
+		ecr.Parse("123456789012.dkr.ecr.us-west-2.amazonaws.com"),
+		ecr.Parse("123456789012.dkr.ecr.eu-west-1.amazonaws.com"),
+		ecr.Parse("public.ecr.aws"),
+	}, registries)
+	assert.Equal(t, 1, regions.calls)
+
+	registries, err = list(context.Background(), fakeCallerIdentity("123456789012"), regions, []string{"cn-north-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []*ecr.Registry{
+		ecr.Parse("123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn"),
+		ecr.Parse("public.ecr.aws"),
+	}, registries)
+	assert.Equal(t, 1, regions.calls)
+}
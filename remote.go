@@ -0,0 +1,67 @@
+package ecr
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// invalidator is implemented by the authenticators of this package to discard their cached token.
+type invalidator interface {
+	invalidate()
+}
+
+// RemoteOptions returns the remote.Options to use keychain with go-containerregistry.
+// Besides authenticating with keychain, requests rejected with a 401 by an ECR registry are retried once with a freshly fetched token,
+// recovering from tokens invalidated before their reported expiry such as when the credentials that minted them were revoked.
+func RemoteOptions(keychain authn.Keychain) []remote.Option {
+	return []remote.Option{
+		remote.WithAuthFromKeychain(keychain),
+		remote.WithTransport(&invalidatingTransport{keychain: keychain, inner: remote.DefaultTransport}),
+		remote.WithUserAgent("docker-credential-ecr/" + version()),
+	}
+}
+
+// invalidatingTransport is an http.RoundTripper that retries requests to ECR registries rejected with a 401 using a new token.
+type invalidatingTransport struct {
+	keychain authn.Keychain
+	inner    http.RoundTripper
+}
+
+func (t *invalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// Only requests authenticated with an ECR token can be fixed by a new token, the body must also be replayable.
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "Basic ") || Parse(req.URL.Host) == nil {
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	authenticator, err := resolveContext(req.Context(), t.keychain, registryResource(req.URL.Host))
+	if err != nil {
+		return resp, nil
+	}
+	invalidator, ok := authenticator.(invalidator)
+	if !ok {
+		return resp, nil
+	}
+	invalidator.invalidate()
+	authConfig, err := authorizationContext(req.Context(), authenticator)
+	if err != nil {
+		return resp, nil
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	retry.SetBasicAuth(authConfig.Username, authConfig.Password)
+	resp.Body.Close()
+	return t.inner.RoundTrip(retry)
+}
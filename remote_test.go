@@ -0,0 +1,62 @@
+package ecr
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripperFunc adapts a function to the http.RoundTripper interface.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestInvalidatingTransport(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	keychain := newTestKeychain("us-west-2/false", client)
+	var requests int
+	transport := &invalidatingTransport{
+		keychain: keychain,
+		inner: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			status := http.StatusOK
+			if requests == 1 {
+				status = http.StatusUnauthorized
+			}
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "blob", string(body))
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}),
+	}
+	req, err := http.NewRequest(http.MethodPut, "https://123456789012.dkr.ecr.us-west-2.amazonaws.com/v2/app/blobs/uploads/", strings.NewReader("blob"))
+	require.NoError(t, err)
+	req.SetBasicAuth("AWS", "revoked")
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, requests)
+	assert.EqualValues(t, 1, client.calls.Load())
+
+	req, err = http.NewRequest(http.MethodPut, "https://index.docker.io/v2/", strings.NewReader("blob"))
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+	requests = 0
+	resp, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRemoteOptions(t *testing.T) {
+	t.Parallel()
+	assert.Len(t, RemoteOptions(newTestKeychain("us-west-2/false", nil)), 3)
+}
@@ -0,0 +1,96 @@
+// Package s3cache implements an ecr.Cache backed by S3 so ephemeral build agents can bootstrap with a token fetched by a previous agent.
+package s3cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+)
+
+// Client is the subset of *s3.Client used by Cache.
+type Client interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Options configures where a Cache stores tokens.
+type Options struct {
+	Bucket string
+	// Prefix is prepended to the key of every object, such as "ecr-tokens/".
+	Prefix string
+	// KMSKeyID encrypts the objects with SSE-KMS using this key, the AWS managed aws/s3 key if empty.
+	KMSKeyID string
+}
+
+// Cache implements ecr.Cache storing each token as a JSON encoded ecr.CachedToken object encrypted with SSE-KMS.
+// The version of S3 used does not support conditional writes, so concurrent writers simply replace each other's equally valid token.
+// A lifecycle rule on the prefix can be used to remove the objects of expired tokens.
+type Cache struct {
+	client Client
+	opts   Options
+	now    func() time.Time
+}
+
+// New returns a Cache storing tokens in the bucket of opts using client.
+func New(client Client, opts Options) *Cache {
+	return &Cache{client: client, opts: opts, now: time.Now}
+}
+
+// Get returns the token stored under key, nil if there is none or it has expired.
+func (c *Cache) Get(ctx context.Context, key string) (*ecr.CachedToken, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.opts.Bucket),
+		Key:    aws.String(c.opts.Prefix + key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("(*s3.Client).GetObject failed: %w", err)
+	}
+	defer out.Body.Close()
+	b, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("(*s3.Client).GetObject failed: %w", err)
+	}
+	var token ecr.CachedToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, fmt.Errorf("(*s3.Client).GetObject returned an invalid token: %w", err)
+	}
+	if !c.now().Before(token.ExpiresAt) {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Put stores token under key.
+func (c *Cache) Put(ctx context.Context, key string, token *ecr.CachedToken) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(c.opts.Bucket),
+		Key:                  aws.String(c.opts.Prefix + key),
+		Body:                 bytes.NewReader(b),
+		ContentType:          aws.String("application/json"),
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+		Expires:              aws.Time(token.ExpiresAt),
+	}
+	if c.opts.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(c.opts.KMSKeyID)
+	}
+	if _, err := c.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("(*s3.Client).PutObject failed: %w", err)
+	}
+	return nil
+}
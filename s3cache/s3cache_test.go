@@ -0,0 +1,66 @@
+package s3cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient implements Client with an in-memory bucket recording the last PutObjectInput.
+type fakeClient struct {
+	objects map[string][]byte
+	input   *s3.PutObjectInput
+}
+
+func (c *fakeClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	b, ok := c.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (c *fakeClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	b, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = b
+	c.input = params
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1700000000, 0).UTC()
+	client := &fakeClient{objects: make(map[string][]byte)}
+	cache := New(client, Options{Bucket: "ci", Prefix: "ecr-tokens/", KMSKeyID: "alias/ci"})
+	cache.now = func() time.Time { return now }
+
+	token, err := cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	fresh := &ecr.CachedToken{Token: "QVdTOnBhc3N3b3Jk", ExpiresAt: now.Add(12 * time.Hour)}
+	require.NoError(t, cache.Put(context.Background(), "ci/us-west-2/false", fresh))
+	assert.Equal(t, "ecr-tokens/ci/us-west-2/false", aws.ToString(client.input.Key))
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, client.input.ServerSideEncryption)
+	assert.Equal(t, "alias/ci", aws.ToString(client.input.SSEKMSKeyId))
+	token, err = cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Equal(t, fresh, token)
+
+	now = now.Add(12 * time.Hour)
+	token, err = cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+}
@@ -0,0 +1,66 @@
+// Package sdkv1 adapts aws-sdk-go (v1) sessions and clients to the ecr package so legacy codebases can adopt it without migrating SDKs first.
+package sdkv1
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecrv2 "github.com/aws/aws-sdk-go-v2/service/ecr"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	ecrv1 "github.com/aws/aws-sdk-go/service/ecr"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// credentialsProvider adapts aws-sdk-go (v1) credentials to an aws.CredentialsProvider.
+type credentialsProvider struct {
+	creds *credentials.Credentials
+}
+
+func (provider credentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	value, err := provider.creds.GetWithContext(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	creds := aws.Credentials{
+		AccessKeyID:     value.AccessKeyID,
+		SecretAccessKey: value.SecretAccessKey,
+		SessionToken:    value.SessionToken,
+		Source:          value.ProviderName,
+	}
+	if expires, err := provider.creds.ExpiresAt(); err == nil {
+		creds.CanExpire = true
+		creds.Expires = expires
+	}
+	return creds, nil
+}
+
+// config converts the region, credentials and HTTP client of an aws-sdk-go (v1) configuration to an aws.Config.
+func config(cfg *awsv1.Config) aws.Config {
+	out := aws.Config{Region: awsv1.StringValue(cfg.Region)}
+	if cfg.Credentials != nil {
+		out.Credentials = credentialsProvider{creds: cfg.Credentials}
+	}
+	if cfg.HTTPClient != nil {
+		out.HTTPClient = cfg.HTTPClient
+	}
+	return out
+}
+
+// NewKeychain returns a new ecr.Keychain using the region and credentials of the given aws-sdk-go (v1) session.
+func NewKeychain(sess *session.Session, opts ...ecr.Option) ecr.Keychain {
+	return ecr.NewKeychain(config(sess.Config), opts...)
+}
+
+// NewAuthenticator returns a new authn.Authenticator using the endpoint, region and credentials of the given aws-sdk-go (v1) ECR client.
+func NewAuthenticator(client *ecrv1.ECR, opts ...ecr.Option) authn.Authenticator {
+	cfg := config(&client.Config)
+	return ecr.NewAuthenticator(ecrv2.NewFromConfig(cfg, func(o *ecrv2.Options) {
+		o.Region = client.SigningRegion
+		if client.Endpoint != "" {
+			o.BaseEndpoint = aws.String(client.Endpoint)
+		}
+	}), opts...)
+}
@@ -0,0 +1,26 @@
+package sdkv1
+
+import (
+	"context"
+	"testing"
+
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+	cfg := config(&awsv1.Config{
+		Region:      awsv1.String("us-west-2"),
+		Credentials: credentials.NewStaticCredentials("AKIAEXAMPLE", "secret", "token"),
+	})
+	assert.Equal(t, "us-west-2", cfg.Region)
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAEXAMPLE", creds.AccessKeyID)
+	assert.Equal(t, "secret", creds.SecretAccessKey)
+	assert.Equal(t, "token", creds.SessionToken)
+	assert.False(t, creds.CanExpire)
+}
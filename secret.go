@@ -0,0 +1,50 @@
+package ecr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// sealer encrypts cached credentials in memory with an ephemeral key that never leaves the process.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+// newSealer returns a sealer with a random AES-256-GCM key.
+func newSealer() *sealer {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	defer clear(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &sealer{aead: aead}
+}
+
+// seal returns plaintext encrypted and prefixed by its random nonce.
+func (s *sealer) seal(plaintext []byte) []byte {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// open decrypts sealed, the caller should clear the returned plaintext once done with it.
+func (s *sealer) open(sealed []byte) ([]byte, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("sealed credentials are truncated")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	return s.aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,96 @@
+// Package secretsmanagercache implements an ecr.Cache backed by AWS Secrets Manager.
+// A privileged publisher using ecr.WithCache stores the tokens it fetches as secrets, which consumers using ecr.WithCache and ecr.WithCacheOnly
+// read without being allowed to call ecr:GetAuthorizationToken themselves.
+package secretsmanagercache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+)
+
+// Client is the subset of *secretsmanager.Client used by Cache.
+type Client interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+}
+
+// Options configures where a Cache stores tokens.
+type Options struct {
+	// Prefix is prepended to the key of every secret name, such as "ecr-tokens/".
+	Prefix string
+	// KMSKeyID encrypts the secrets created with this key, the AWS managed aws/secretsmanager key if empty.
+	KMSKeyID string
+}
+
+// Cache implements ecr.Cache storing each token as a JSON encoded ecr.CachedToken secret, creating the secret on the first Put.
+type Cache struct {
+	client Client
+	opts   Options
+	now    func() time.Time
+}
+
+// New returns a Cache storing tokens as secrets using client.
+func New(client Client, opts Options) *Cache {
+	return &Cache{client: client, opts: opts, now: time.Now}
+}
+
+// Get returns the token stored under key, nil if there is none or it has expired.
+func (c *Cache) Get(ctx context.Context, key string) (*ecr.CachedToken, error) {
+	out, err := c.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(c.opts.Prefix + key),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("(*secretsmanager.Client).GetSecretValue failed: %w", err)
+	}
+	var token ecr.CachedToken
+	if err := json.Unmarshal([]byte(aws.ToString(out.SecretString)), &token); err != nil {
+		return nil, fmt.Errorf("(*secretsmanager.Client).GetSecretValue returned an invalid token: %w", err)
+	}
+	if !c.now().Before(token.ExpiresAt) {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Put stores token under key as a new version of its secret, creating the secret if it does not exist.
+func (c *Cache) Put(ctx context.Context, key string, token *ecr.CachedToken) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(c.opts.Prefix + key),
+		SecretString: aws.String(string(b)),
+	})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		if err != nil {
+			return fmt.Errorf("(*secretsmanager.Client).PutSecretValue failed: %w", err)
+		}
+		return nil
+	}
+	input := &secretsmanager.CreateSecretInput{
+		Name:         aws.String(c.opts.Prefix + key),
+		SecretString: aws.String(string(b)),
+		Description:  aws.String("ECR authorization token"),
+	}
+	if c.opts.KMSKeyID != "" {
+		input.KmsKeyId = aws.String(c.opts.KMSKeyID)
+	}
+	if _, err := c.client.CreateSecret(ctx, input); err != nil {
+		return fmt.Errorf("(*secretsmanager.Client).CreateSecret failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package secretsmanagercache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	ecr "github.com/bored-engineer/docker-credential-ecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient implements Client with in-memory secrets recording the CreateSecretInputs.
+type fakeClient struct {
+	secrets map[string]string
+	created []*secretsmanager.CreateSecretInput
+}
+
+func (c *fakeClient) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	value, ok := c.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func (c *fakeClient) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	if _, ok := c.secrets[aws.ToString(params.SecretId)]; !ok {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	c.secrets[aws.ToString(params.SecretId)] = aws.ToString(params.SecretString)
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (c *fakeClient) CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	c.created = append(c.created, params)
+	c.secrets[aws.ToString(params.Name)] = aws.ToString(params.SecretString)
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1700000000, 0).UTC()
+	client := &fakeClient{secrets: make(map[string]string)}
+	cache := New(client, Options{Prefix: "ecr-tokens/", KMSKeyID: "alias/ci"})
+	cache.now = func() time.Time { return now }
+
+	token, err := cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cache.Put(context.Background(), "ci/us-west-2/false", &ecr.CachedToken{Token: "QVdTOnBhc3N3b3Jk", ExpiresAt: now.Add(12 * time.Hour)}))
+	}
+	require.Len(t, client.created, 1)
+	assert.Equal(t, "ecr-tokens/ci/us-west-2/false", aws.ToString(client.created[0].Name))
+	assert.Equal(t, "alias/ci", aws.ToString(client.created[0].KmsKeyId))
+	token, err = cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Equal(t, &ecr.CachedToken{Token: "QVdTOnBhc3N3b3Jk", ExpiresAt: now.Add(12 * time.Hour)}, token)
+
+	now = now.Add(12 * time.Hour)
+	token, err = cache.Get(context.Background(), "ci/us-west-2/false")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+}
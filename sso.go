@@ -0,0 +1,96 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+)
+
+// SSOLoginRequiredError is returned when a token cannot be fetched because the AWS SSO session of the profile has expired.
+type SSOLoginRequiredError struct {
+	Profile  string
+	StartURL string
+	Err      error
+}
+
+func (e *SSOLoginRequiredError) Error() string {
+	if e.StartURL == "" {
+		return fmt.Sprintf("the AWS SSO session of profile %q has expired, run: %s", e.Profile, e.Command())
+	}
+	return fmt.Sprintf("the AWS SSO session of profile %q (%s) has expired, run: %s", e.Profile, e.StartURL, e.Command())
+}
+
+func (e *SSOLoginRequiredError) Unwrap() error {
+	return e.Err
+}
+
+// Command returns the AWS CLI command that starts a new SSO session for the profile.
+func (e *SSOLoginRequiredError) Command() string {
+	return "aws sso login --profile " + e.Profile
+}
+
+// sharedConfigSource is the profile and shared config files the AWS configuration of a keychain is loaded from.
+type sharedConfigSource struct {
+	profile          string
+	configFiles      []string
+	credentialsFiles []string
+}
+
+// sharedConfig returns the profile and shared config files selected by the load options, such as those of WithProfile and WithSharedConfigFiles,
+// falling back to the environment and the SDK defaults like config.LoadDefaultConfig.
+func (o *options) sharedConfig() sharedConfigSource {
+	var loadOptions config.LoadOptions
+	for _, fn := range o.loadOptions {
+		_ = fn(&loadOptions)
+	}
+	source := sharedConfigSource{
+		profile:          loadOptions.SharedConfigProfile,
+		configFiles:      loadOptions.SharedConfigFiles,
+		credentialsFiles: loadOptions.SharedCredentialsFiles,
+	}
+	for _, env := range []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE"} {
+		if source.profile == "" {
+			source.profile = os.Getenv(env)
+		}
+	}
+	if source.profile == "" {
+		source.profile = config.DefaultSharedConfigProfile
+	}
+	if source.configFiles == nil {
+		source.configFiles = []string{config.DefaultSharedConfigFilename()}
+		if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+			source.configFiles = []string{path}
+		}
+	}
+	if source.credentialsFiles == nil {
+		source.credentialsFiles = []string{config.DefaultSharedCredentialsFilename()}
+		if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+			source.credentialsFiles = []string{path}
+		}
+	}
+	return source
+}
+
+// ssoLoginRequired returns an *SSOLoginRequiredError if err was caused by an expired AWS SSO session of the profile of source, otherwise nil.
+func ssoLoginRequired(ctx context.Context, err error, source sharedConfigSource) error {
+	var invalidToken *ssocreds.InvalidTokenError
+	if !errors.As(err, &invalidToken) {
+		return nil
+	}
+	ssoErr := &SSOLoginRequiredError{Profile: source.profile, Err: err}
+	shared, err := config.LoadSharedConfigProfile(ctx, source.profile, func(o *config.LoadSharedConfigOptions) {
+		o.ConfigFiles = source.configFiles
+		o.CredentialsFiles = source.credentialsFiles
+	})
+	if err == nil {
+		ssoErr.StartURL = shared.SSOStartURL
+		if shared.SSOSession != nil && shared.SSOSession.SSOStartURL != "" {
+			ssoErr.StartURL = shared.SSOSession.SSOStartURL
+		}
+	}
+	return ssoErr
+}
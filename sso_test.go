@@ -0,0 +1,57 @@
+package ecr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticatorSSOLoginRequired(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "dev")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/config")
+	client := &fakeClient{err: &ssocreds.InvalidTokenError{Err: errors.New("token expired")}}
+	_, err := newAuthenticator(client, newOptions(nil)).Authorization()
+	var ssoErr *SSOLoginRequiredError
+	require.ErrorAs(t, err, &ssoErr)
+	assert.Equal(t, "dev", ssoErr.Profile)
+	assert.Equal(t, "aws sso login --profile dev", ssoErr.Command())
+	var invalidToken *ssocreds.InvalidTokenError
+	assert.ErrorAs(t, err, &invalidToken)
+
+	_, err = newAuthenticator(&fakeClient{err: errors.New("boom")}, newOptions(nil)).Authorization()
+	assert.False(t, errors.As(err, &ssoErr))
+}
+
+func TestAuthenticatorSSOLoginRequiredProfile(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "legacy")
+	configFile := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(configFile, []byte("[profile prod]\nsso_start_url = https://example.awsapps.com/start\n"), 0o600))
+	client := &fakeClient{err: &ssocreds.InvalidTokenError{Err: errors.New("token expired")}}
+	tests := map[string]struct {
+		opts     []Option
+		profile  string
+		startURL string
+	}{
+		"default profile env": {profile: "legacy"},
+		"profile and files": {
+			opts:     []Option{WithProfile("prod"), WithSharedConfigFiles([]string{configFile}, nil)},
+			profile:  "prod",
+			startURL: "https://example.awsapps.com/start",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newAuthenticator(client, newOptions(tt.opts)).Authorization()
+			var ssoErr *SSOLoginRequiredError
+			require.ErrorAs(t, err, &ssoErr)
+			assert.Equal(t, tt.profile, ssoErr.Profile)
+			assert.Equal(t, tt.startURL, ssoErr.StartURL)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/oauth2"
+)
+
+// expiringAuthenticator is implemented by the authenticators of this package that know when their credentials expire.
+// Authenticators wrapping one must implement it too, otherwise the APIs reporting expiry treat their credentials as never expiring.
+type expiringAuthenticator interface {
+	authorizationExpiry(ctx context.Context) (*authn.AuthConfig, time.Time, error)
+}
+
+// authorization returns the credentials of authenticator along with when they expire, zero if unknown.
+func authorization(ctx context.Context, authenticator authn.Authenticator) (*authn.AuthConfig, time.Time, error) {
+	if authenticator, ok := authenticator.(expiringAuthenticator); ok {
+		return authenticator.authorizationExpiry(ctx)
+	}
+	authConfig, err := authorizationContext(ctx, authenticator)
+	return authConfig, time.Time{}, err
+}
+
+// tokenSource implements oauth2.TokenSource on top of an authn.Authenticator.
+type tokenSource struct {
+	authenticator authn.Authenticator
+}
+
+func (ts tokenSource) Token() (*oauth2.Token, error) {
+	authConfig, expiry, err := authorization(defaultContext(ts.authenticator), ts.authenticator)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{
+		TokenType:   "Basic",
+		AccessToken: base64.StdEncoding.EncodeToString([]byte(authConfig.Username + ":" + authConfig.Password)),
+		Expiry:      expiry,
+	}, nil
+}
+
+// NewTokenSource exposes the credentials of authenticator as an oauth2.TokenSource.
+// The AccessToken is the raw base64 encoded ECR authorization token and Expiry is when the cached token will be refreshed,
+// authenticators not created by this package are reported as never expiring.
+func NewTokenSource(authenticator authn.Authenticator) oauth2.TokenSource {
+	return tokenSource{authenticator: authenticator}
+}
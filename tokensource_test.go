@@ -0,0 +1,36 @@
+package ecr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSource(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	token, err := NewTokenSource(newAuthenticator(client, newOptions(nil))).Token()
+	require.NoError(t, err)
+	assert.Equal(t, client.token, token.AccessToken)
+	assert.Equal(t, "Basic", token.TokenType)
+	assert.WithinDuration(t, client.expiresAt.Add(-DefaultEarlyExpiry), token.Expiry, time.Second)
+	assert.True(t, token.Valid())
+}
+
+func TestTokenSourceBaseContext(t *testing.T) {
+	t.Parallel()
+	client := newFakeClient("AWS", "password", 12*time.Hour)
+	var value any
+	authenticator := newAuthenticator(funcGetAuthorizationToken(func(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+		value = ctx.Value(contextKey{})
+		return client.GetAuthorizationToken(ctx, params, optFns...)
+	}), newOptions([]Option{WithBaseContext(context.WithValue(context.Background(), contextKey{}, "base"))}))
+	_, err := NewTokenSource(authenticator).Token()
+	require.NoError(t, err)
+	assert.Equal(t, "base", value)
+}
@@ -0,0 +1,33 @@
+package ecr
+
+import (
+	"runtime/debug"
+	"sync"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const modulePath = "github.com/bored-engineer/docker-credential-ecr"
+
+// version returns the version of this module recorded in the build info, or "devel" if it is unknown.
+var version = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "devel"
+})
+
+// addUserAgent appends a docker-credential-ecr/<version> clause to the User-Agent so usage is identifiable in CloudTrail.
+func addUserAgent(stack *middleware.Stack) error {
+	return awsmiddleware.AddUserAgentKeyValue("docker-credential-ecr", version())(stack)
+}
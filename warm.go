@@ -0,0 +1,138 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultWarmConcurrency bounds how many registries Warm fetches tokens for in parallel.
+var DefaultWarmConcurrency = 8
+
+// registryResource implements authn.Resource for a bare registry hostname.
+type registryResource string
+
+func (r registryResource) String() string      { return string(r) }
+func (r registryResource) RegistryStr() string { return string(r) }
+
+// Warm resolves each registry and fetches its token concurrently, returning the errors of every registry that failed.
+func (keychain *ecrKeychain) Warm(ctx context.Context, registries ...string) error {
+	errs := make([]error, len(registries))
+	forEachRegistry(registries, DefaultWarmConcurrency, func(idx int, registry string) {
+		if err := ctx.Err(); err != nil {
+			errs[idx] = err
+			return
+		}
+		authenticator, err := keychain.ResolveContext(ctx, registryResource(registry))
+		if err == nil {
+			_, err = authorizationContext(ctx, authenticator)
+		}
+		if err != nil {
+			errs[idx] = fmt.Errorf("failed to warm %s: %w", registry, err)
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// forEachRegistry calls fn with every registry and its index concurrently, running at most concurrency (DefaultWarmConcurrency if zero) at once.
+func forEachRegistry(registries []string, concurrency int, fn func(idx int, registry string)) {
+	if concurrency <= 0 {
+		concurrency = DefaultWarmConcurrency
+	}
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+	for idx, registry := range registries {
+		idx, registry := idx, registry
+		g.Go(func() error {
+			fn(idx, registry)
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// LoginResult is the outcome of LoginAll for a registry.
+type LoginResult struct {
+	Registry   string
+	AuthConfig authn.AuthConfig
+	// ExpiresAt is when the credentials will be refreshed, zero if they never expire.
+	ExpiresAt time.Time
+	Err       error
+}
+
+// LoginOptions configures LoginAll.
+type LoginOptions struct {
+	// Concurrency bounds how many registries are logged in to in parallel, DefaultWarmConcurrency if zero.
+	Concurrency int
+}
+
+// LoginAll fetches the credentials of every registry with keychain concurrently, such as for CI jobs pushing to many accounts and regions.
+// The results are in the order of registries, a registry that failed has its Err set without affecting the others.
+func LoginAll(ctx context.Context, keychain authn.Keychain, registries []string, opts LoginOptions) []LoginResult {
+	results := make([]LoginResult, len(registries))
+	forEachRegistry(registries, opts.Concurrency, func(idx int, registry string) {
+		results[idx] = login(ctx, keychain, registry)
+	})
+	return results
+}
+
+// login implements LoginAll for a single registry.
+func login(ctx context.Context, keychain authn.Keychain, registry string) LoginResult {
+	result := LoginResult{Registry: registry}
+	if result.Err = ctx.Err(); result.Err != nil {
+		return result
+	}
+	authenticator, err := resolveContext(ctx, keychain, registryResource(registry))
+	if err != nil {
+		result.Err = fmt.Errorf("failed to resolve %s: %w", registry, err)
+		return result
+	}
+	authConfig, expiresAt, err := authorization(ctx, authenticator)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to authenticate to %s: %w", registry, err)
+		return result
+	}
+	result.AuthConfig, result.ExpiresAt = *authConfig, expiresAt
+	return result
+}
+
+// ResolveAll resolves the registries of resources concurrently, fetching their tokens too if authorize is set.
+// The authenticators are keyed by registry, registries that failed are omitted and their errors joined.
+func (keychain *ecrKeychain) ResolveAll(ctx context.Context, resources []authn.Resource, authorize bool) (map[string]authn.Authenticator, error) {
+	var registries []string
+	seen := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		if registry := resource.RegistryStr(); !seen[registry] {
+			seen[registry] = true
+			registries = append(registries, registry)
+		}
+	}
+	authenticators := make([]authn.Authenticator, len(registries))
+	errs := make([]error, len(registries))
+	forEachRegistry(registries, DefaultWarmConcurrency, func(idx int, registry string) {
+		if err := ctx.Err(); err != nil {
+			errs[idx] = err
+			return
+		}
+		authenticator, err := keychain.ResolveContext(ctx, registryResource(registry))
+		if err == nil && authorize {
+			_, err = authorizationContext(ctx, authenticator)
+		}
+		if err != nil {
+			errs[idx] = fmt.Errorf("failed to resolve %s: %w", registry, err)
+			return
+		}
+		authenticators[idx] = authenticator
+	})
+	resolved := make(map[string]authn.Authenticator, len(registries))
+	for idx, registry := range registries {
+		if errs[idx] == nil {
+			resolved[registry] = authenticators[idx]
+		}
+	}
+	return resolved, errors.Join(errs...)
+}
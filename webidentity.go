@@ -0,0 +1,26 @@
+package ecr
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// webIdentity is the role assumed with sts:AssumeRoleWithWebIdentity configured by WithWebIdentity.
+type webIdentity struct {
+	roleARN     string
+	tokenFile   string
+	sessionName string
+}
+
+// provider returns the cached credentials of the role, assumed using the configuration of cfg.
+func (identity *webIdentity) provider(cfg aws.Config, optFns ...func(*sts.Options)) aws.CredentialsProvider {
+	return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+		sts.NewFromConfig(cfg, optFns...),
+		identity.roleARN,
+		stscreds.IdentityTokenFile(identity.tokenFile),
+		func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = identity.sessionName
+		},
+	))
+}
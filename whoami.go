@@ -0,0 +1,67 @@
+package ecr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Identity describes the AWS caller of an aws.Config and its own private registry.
+type Identity struct {
+	AccountID string
+	ARN       string
+	UserID    string
+	Region    string
+	Partition string
+	// Registry is the private registry of the account in Region, nil if no region is configured.
+	Registry *Registry
+}
+
+// callerIdentityClient is the subset of *sts.Client used by whoAmI and defaultRegistry.
+type callerIdentityClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// WhoAmI returns the sts:GetCallerIdentity of cfg along with the region, partition and default private registry it resolves to.
+func WhoAmI(ctx context.Context, cfg aws.Config) (*Identity, error) {
+	return whoAmI(ctx, sts.NewFromConfig(cfg), cfg.Region)
+}
+
+// whoAmI implements WhoAmI using client.
+func whoAmI(ctx context.Context, client callerIdentityClient, region string) (*Identity, error) {
+	out, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("(*sts.Client).GetCallerIdentity failed: %w", err)
+	}
+	identity := &Identity{
+		AccountID: aws.ToString(out.Account),
+		ARN:       aws.ToString(out.Arn),
+		UserID:    aws.ToString(out.UserId),
+		Region:    region,
+	}
+	if region != "" {
+		identity.Registry = &Registry{AccountID: identity.AccountID, Region: region, DNSSuffix: dnsSuffixForRegion(region)}
+		identity.Partition = identity.Registry.Partition()
+	}
+	return identity, nil
+}
+
+// DefaultRegistry returns the private registry of the caller's own account in the region of cfg, such as for logging in to "my registry".
+func DefaultRegistry(ctx context.Context, cfg aws.Config) (*Registry, error) {
+	return defaultRegistry(ctx, sts.NewFromConfig(cfg), cfg.Region)
+}
+
+// defaultRegistry implements DefaultRegistry using client.
+func defaultRegistry(ctx context.Context, client callerIdentityClient, region string) (*Registry, error) {
+	if region == "" {
+		return nil, errors.New("no AWS region is configured")
+	}
+	identity, err := whoAmI(ctx, client, region)
+	if err != nil {
+		return nil, err
+	}
+	return identity.Registry, nil
+}
@@ -0,0 +1,45 @@
+package ecr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCallerIdentity implements callerIdentityClient returning a fixed ARN, the account is taken from the ARN.
+type fakeCallerIdentity string
+
+func (arn fakeCallerIdentity) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Arn:     aws.String(string(arn)),
+		Account: aws.String(strings.Split(string(arn), ":")[4]),
+	}, nil
+}
+
+func TestWhoAmI(t *testing.T) {
+	t.Parallel()
+	identity, err := whoAmI(context.Background(), fakeCallerIdentity("arn:aws-cn:iam::123456789012:user/dev"), "cn-north-1")
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012", identity.AccountID)
+	assert.Equal(t, "aws-cn", identity.Partition)
+	assert.Equal(t, "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", identity.Registry.String())
+
+	identity, err = whoAmI(context.Background(), fakeCallerIdentity("arn:aws:iam::123456789012:user/dev"), "")
+	require.NoError(t, err)
+	assert.Nil(t, identity.Registry)
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	t.Parallel()
+	reg, err := defaultRegistry(context.Background(), fakeCallerIdentity("arn:aws:iam::123456789012:user/dev"), "us-west-2")
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012.dkr.ecr.us-west-2.amazonaws.com", reg.String())
+
+	_, err = defaultRegistry(context.Background(), fakeCallerIdentity("arn:aws:iam::123456789012:user/dev"), "")
+	assert.Error(t, err)
+}